@@ -1,50 +1,318 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"path"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
 	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/queue"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
 	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
 )
 
-func actionGenres(out string) {
-	fmt.Println("Starting genres loading")
-	genres, errs := genre.GetGenres(genre.GetRequestOptions())
-	stopOnErrors(errs)
+// detailsAttempts is the number of lookups of the single show before it is left as failed
+const detailsAttempts = 3
+
+// stages of the per item loading, used in the failures file
+const (
+	stageDetails = "details"
+	stagePages   = "pages"
+	stageFeed    = "feed"
+)
+
+// other stages, used in the manifest, failed pages of genres and shows are recorded to the failures file too
+const (
+	stageGenres  = "genres"
+	stageShows   = "shows"
+	stageCompact = "compact"
+	stageRetry   = "retry-failed"
+)
+
+// stage actions return the number of loaded items and errors, the failure of the whole stage stops the process
 
-	fmt.Println("Genres loaded", len(genres))
-	err := genre.Save(path.Join(out, "genres.json"), genres)
+func actionGenres(rs *rules.Rules, country string, out static.Store, fails *failures.File) (int, []error) {
+	opt := genre.GetRequestOptions(rs.Get("genre"), country)
+	genres, errs := genre.GetGenres(opt)
+	if !recordScrapeFailures(fails, stageGenres, errs, getPageIDs(opt.LookupURL)) {
+		return 0, finishStage(errs, out, fails)
+	}
+
+	crawler.Infof("Genres loaded %d", len(genres))
+	err := genre.Save(out, genres)
 	stopOnError(err)
+	return len(genres), finishStage(errs, out, fails)
 }
 
-func actionShows(genrePath string, out string) {
-	fmt.Println("Starting shows loading")
+// actionShows loads shows of genres selected by the filter
+func actionShows(genrePath string, rs *rules.Rules, fo *filterOptions, out static.Store, fails *failures.File) (int, []error) {
 	genres, err := genre.GetGenresFromFile(genrePath)
 	stopOnError(err)
 
-	shows, errs := show.GetShows(show.GetShowsRequestOptions(genres))
-	stopOnErrors(errs)
+	flt, err := fo.getShowFilter(genres)
+	stopOnError(err)
+	if flt != nil {
+		genres = flt.Genres.Filter(genres)
+		crawler.Infof("Genres selected %d", len(genres))
+	}
+
+	// shows of the loaded pages are saved even if other pages failed, retry-failed merges the rest into them
+	shows, errs := show.GetShows(show.GetShowsRequestOptions(genres, rs.Get("show")))
+	shows = flt.Shows(shows)
 
-	fmt.Println("Shows loaded", len(shows))
-	err = show.Save(path.Join(out, "shows.json"), shows)
+	crawler.Infof("Shows loaded %d", len(shows))
+	err = show.Save(out, shows)
 	stopOnError(err)
+	recordScrapeFailures(fails, stageShows, errs, getGenrePageIDs(genres))
+	return len(shows), finishStage(errs, out, fails)
 }
 
-func actionDetails(showPath string, delay int, chunk int, out string) {
-	fmt.Println("Starting details loading")
+// recordScrapeFailures records failed pages of the stage, ids are IDs of page URLs, e.g. genre IDs of genre pages.
+// Pages without errors are resolved, it returns false when the stage has errors.
+func recordScrapeFailures(fails *failures.File, stage string, errs []error, ids map[string]int) bool {
+	failed := map[string]bool{}
+	for _, err := range errs {
+		// errors which are not tied to the page (e.g. IDs which cannot be parsed) fail the stage only
+		if url := crawler.GetScrapeURL(err); url != "" {
+			addFailure(fails, stage, ids[url], url, err)
+			failed[url] = true
+		}
+	}
+	for url, id := range ids {
+		if !failed[url] {
+			fails.Resolve(stage, id)
+		}
+	}
+	return len(errs) == 0
+}
+
+// getPageIDs returns IDs of page URLs parsed from URLs, e.g. 26 of the genre page of all podcasts
+func getPageIDs(urls []string) map[string]int {
+	ids := make(map[string]int, len(urls))
+	for _, url := range urls {
+		ids[url], _ = crawler.GetEntityIDFromURL(url)
+	}
+	return ids
+}
+
+func getGenrePageIDs(genres []*genre.Genre) map[string]int {
+	ids := make(map[string]int, len(genres))
+	for _, genre := range genres {
+		ids[genre.URL] = genre.ID
+	}
+	return ids
+}
+
+// actionDetails loads details of the next chunk of queued shows, in the loop mode chunks are loaded until the queue is done
+func actionDetails(showPath string, th *crawler.Throttle, chunk int, loop bool, fo *filterOptions, outDir string, out static.Store, fails *failures.File) (int, []error) {
 	shows, err := show.GetShowsFromFile(showPath)
 	stopOnError(err)
-	fmt.Println("Shows total", len(shows))
+	crawler.Infof("Shows total %d", len(shows))
+
+	// blocked shows are not looked up, shows of excluded genres are kept in the queue only
+	flt := loadShowFilter(fo, out)
+	shows = flt.Shows(shows)
 
-	file := path.Join(out, "shows.details.json")
-	cache, _ := show.GetShowDetailsFromFile(file)
-	fmt.Println("Details found", len(cache))
+	q, err := openDetailsQueue(outDir, out, flt)
+	stopOnError(err)
+	defer q.Close()
+
+	ids := make([]int, 0, len(shows))
+	for _, show := range shows {
+		ids = append(ids, show.ID)
+	}
+	_, err = q.Add(ids...)
+	stopOnError(err)
+
+	done, stop := notifyStop("Stopping details loading, the progress is saved")
+	defer stop()
+
+	loaded := 0
+	errs := []error{}
+	for next := q.Next(chunk, detailsAttempts); len(next) > 0; next = q.Next(chunk, detailsAttempts) {
+		fresh := make([]*show.Show, 0, len(next))
+		blocked := []int{}
+		for _, id := range next {
+			if !flt.AllowsID(id) {
+				blocked = append(blocked, id)
+				continue
+			}
+			if item, ok := q.Get(id); ok && item.State == queue.Failed {
+				crawler.Retry(id)
+			}
+			fresh = append(fresh, &show.Show{ID: id})
+		}
+		_, err = q.Skip(blocked...)
+		stopOnError(err)
+
+		n, cerrs := fetchDetails(fresh, th, flt, q, out, fails, done)
+		loaded += n
+		errs = append(errs, cerrs...)
+		if !loop || isStopped(done) || hasBudgetError(cerrs) {
+			break
+		}
+	}
+
+	// the finished queue is compacted, so the log keeps the single record of every show across runs
+	if q.Finished(detailsAttempts) {
+		if err := q.Compact(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	stats := q.Stats()
+	crawler.Infof("Details loaded %d", loaded)
+	crawler.Infof("Lookup rate %.2f req/s, rate limited %d times", th.Rate(), th.Bans())
+	crawler.Infof(
+		"Queue pending %d, done %d, failed %d, skipped %d",
+		stats[queue.Pending], stats[queue.Done], stats[queue.Failed], stats[queue.Skipped],
+	)
+	return loaded, finishStage(errs, out, fails)
+}
+
+// openDetailsQueue opens the details queue and syncs it with the details store
+func openDetailsQueue(outDir string, out static.Store, flt *show.Filter) (*queue.Queue, error) {
+	q, err := queue.Open(filepath.Join(outDir, show.DetailsKind+".queue.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	cache, _ := out.List(show.DetailsKind)
+	crawler.CacheHits(len(cache), "Details found")
+
+	// the queue is the source of truth, results saved to the queue before the crash are restored to the store
+	restored, err := restoreDetails(q, cache, out, flt)
+	if err == nil {
+		_, err = q.MarkDone(cache...)
+	}
+	if err != nil {
+		q.Close()
+		return nil, err
+	}
+	if restored > 0 {
+		crawler.Infof("Details restored %d", restored)
+	}
+	return q, nil
+}
 
+// restoreDetails puts the queued results missing in the store, e.g. when the store was not flushed.
+// Results are restored when the filter allows them, so shows of excluded genres are back when the filter changes.
+func restoreDetails(q *queue.Queue, cache []int, out static.Store, flt *show.Filter) (int, error) {
 	inCache := make(map[int]int, len(cache))
-	for _, show := range cache {
-		inCache[show.ID] = 1
+	for _, id := range cache {
+		inCache[id] = 1
+	}
+
+	restored := 0
+	for _, id := range q.IDs() {
+		if _, ok := inCache[id]; ok {
+			continue
+		}
+		details := &show.ShowDetails{}
+		if err := q.Result(id, details); err == static.ErrNotFound {
+			continue
+		} else if err != nil {
+			return restored, err
+		}
+		if !flt.AllowsDetails(id, details) {
+			continue
+		}
+		if err := out.Put(show.DetailsKind, details.ID, details); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// notifyStop returns the channel which is closed when the process is interrupted, stop releases the signal handler
+func notifyStop(msg string) (<-chan struct{}, func()) {
+	done := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sig; ok {
+			crawler.Infof("%s", msg)
+			close(done)
+		}
+	}()
+	return done, func() {
+		signal.Stop(sig)
+		close(sig)
+	}
+}
+
+func isStopped(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// fetchDetails looks up details of the shows until all are loaded or done is closed, details not allowed by the filter are not saved
+func fetchDetails(shows []*show.Show, th *crawler.Throttle, flt *show.Filter, q *queue.Queue, out static.Store, fails *failures.File, done <-chan struct{}) (int, []error) {
+	loaded := 0
+	errs := []error{}
+	opt := show.GetDetailsRequestOptions(shows, th.Delay())
+	opt.Throttle = th
+	opt.Done = done
+	show.StreamDetails(opt, func(res *show.DetailsResult) {
+		if crawler.IsBudgetError(res.Error) {
+			// the show is not failed, it stays pending for the next run
+			errs = append(errs, res.Error)
+			return
+		}
+		crawler.ItemDone(res.ID, res.URL, res.Error)
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			addFailure(fails, stageDetails, res.ID, res.URL, res.Error)
+			if err := q.Fail(res.ID, res.Error); err != nil {
+				errs = append(errs, err)
+			}
+			return
+		}
+		if err := q.Done(res.ID, res.Details); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		fails.Resolve(stageDetails, res.ID)
+		if !flt.AllowsDetails(res.ID, res.Details) {
+			return
+		}
+		if err := out.Put(show.DetailsKind, res.Details.ID, res.Details); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		loaded++
+	})
+	return loaded, errs
+}
+
+func actionPages(showPath string, delay int, chunk int, out static.Store, fails *failures.File) (int, []error) {
+	shows, err := show.GetShowsFromFile(showPath)
+	stopOnError(err)
+	crawler.Infof("Shows total %d", len(shows))
+
+	cache, _ := out.List(show.PagesKind)
+	crawler.CacheHits(len(cache), "Pages found")
+
+	inCache := make(map[int]int, len(cache))
+	for _, id := range cache {
+		inCache[id] = 1
 	}
 
 	fresh := make([]*show.Show, 0, chunk)
@@ -54,49 +322,239 @@ func actionDetails(showPath string, delay int, chunk int, out string) {
 		}
 	}
 
-	details, errs := show.GetDetails(show.GetDetailsRequestOptions(
-		fresh,
-		(time.Duration)(delay)*time.Second),
-	)
-	stopOnErrors(errs)
+	loaded, errs := fetchPages(fresh, delay, out, fails)
 
-	fmt.Println("Details loaded", len(details))
-	cache = append(cache, details...)
-	err = show.SaveDetails(file, cache)
-	stopOnError(err)
+	crawler.Infof("Pages loaded %d", loaded)
+	return loaded, finishStage(errs, out, fails)
+}
+
+func fetchPages(shows []*show.Show, delay int, out static.Store, fails *failures.File) (int, []error) {
+	loaded := 0
+	errs := []error{}
+	opt := show.GetPagesRequestOptions(shows, (time.Duration)(delay)*time.Second)
+	show.StreamPages(opt, func(res *show.PageResult) {
+		if crawler.IsBudgetError(res.Error) {
+			errs = append(errs, res.Error)
+			return
+		}
+		if res.Error == nil {
+			res.Error = out.Put(show.PagesKind, res.Page.ID, res.Page)
+		}
+		crawler.ItemDone(res.ID, res.URL, res.Error)
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			addFailure(fails, stagePages, res.ID, res.URL, res.Error)
+			return
+		}
+		fails.Resolve(stagePages, res.ID)
+		loaded++
+	})
+	return loaded, errs
 }
 
-func actionFeed(detailPath string, out string) {
-	fmt.Println("Starting feed loading")
+func actionFeed(detailPath string, ttl time.Duration, fo *filterOptions, out static.Store, fails *failures.File) (int, []error) {
 	details, err := show.GetShowDetailsFromFile(detailPath)
 	stopOnError(err)
+	crawler.Infof("Details found %d", len(details))
 
-	fmt.Println("Details found", len(details))
-	feeds, errs := show.GetFeed(details)
+	if flt := loadShowFilter(fo, out); flt != nil {
+		details = flt.Details(details)
+		crawler.Infof("Details selected %d", len(details))
+	}
 
-	fmt.Println("Feeds loaded", len(feeds))
-	err = show.SaveFeed(path.Join(out, "shows.feed.json"), feeds)
-	if err != nil {
+	// shows without the feed URL are not requested, so they are counted neither as fresh nor as failed
+	details, skipped := show.GetShowsWithFeed(details)
+	if skipped > 0 {
+		crawler.Infof("Feeds skipped %d, no feed URL", skipped)
+	}
+
+	cached := getCachedFeeds(out)
+	stale := show.GetStaleShows(details, cached, ttl, time.Now().UTC())
+	crawler.CacheHits(len(details)-len(stale), "Feeds fresh")
+
+	loaded, errs := fetchFeeds(stale, cached, out, fails)
+
+	crawler.Infof("Feeds loaded %d", loaded)
+	crawler.Infof("Feeds failed %d", len(stale)-loaded)
+	return loaded, finishStage(errs, out, fails)
+}
+
+func getCachedFeeds(out static.Store) map[int]*show.Feed {
+	feeds, _ := show.LoadFeeds(out)
+	cached := make(map[int]*show.Feed, len(feeds))
+	for _, feed := range feeds {
+		cached[feed.ID] = feed
+	}
+	return cached
+}
+
+// fetchFeeds loads feeds of the shows and merges them with the cached ones
+func fetchFeeds(shows []*show.ShowDetails, cached map[int]*show.Feed, out static.Store, fails *failures.File) (int, []error) {
+	now := time.Now().UTC()
+	loaded := 0
+	errs := []error{}
+	budget := false
+	show.StreamFeed(shows, func(res *show.FeedResult) {
+		if crawler.IsBudgetError(res.Error) {
+			// feeds are requested concurrently, the exhausted budget is reported once and feeds are kept as they are
+			if !budget {
+				errs = append(errs, res.Error)
+			}
+			budget = true
+			return
+		}
+		crawler.ItemDone(res.ID, res.URL, res.Error)
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			addFailure(fails, stageFeed, res.ID, res.URL, res.Error)
+		} else {
+			fails.Resolve(stageFeed, res.ID)
+			loaded++
+		}
+		if err := out.Put(show.FeedKind, res.ID, show.MergeFeed(cached[res.ID], res, now)); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	return loaded, errs
+}
+
+// actionRetryFailed loads again items of the failures file and merges them into the existing outputs
+func actionRetryFailed(rs *rules.Rules, delay int, th *crawler.Throttle, fo *filterOptions, outDir string, out static.Store, fails *failures.File) (int, []error) {
+	crawler.Infof("Failures to retry %d", fails.Len())
+	errs := []error{}
+	total := 0
+	flt := loadShowFilter(fo, out)
+
+	// genres and shows go first, so the later stages see them
+	if list := fails.List(stageGenres); len(list) > 0 {
+		for _, item := range list {
+			crawler.Retry(item.ID)
+		}
+		opt := rs.Get("genre").GetScraperOptions(getFailureURLs(list))
+		genres, gerrs := genre.GetGenres(opt)
+		if recordScrapeFailures(fails, stageGenres, gerrs, getPageIDs(opt.LookupURL)) {
+			stopOnError(genre.Save(out, genres))
+			total += len(genres)
+		}
+		crawler.Infof("Genre pages retried %d, genres loaded %d", len(list), len(genres))
+		errs = append(errs, gerrs...)
+	}
+
+	if list := fails.List(stageShows); len(list) > 0 {
+		ids := map[string]int{}
+		for _, item := range list {
+			crawler.Retry(item.ID)
+			ids[item.URL] = item.ID
+		}
+		shows, serrs := show.GetShows(rs.Get("show").GetScraperOptions(getFailureURLs(list)))
+		recordScrapeFailures(fails, stageShows, serrs, ids)
+		// shows of retried genres are merged into the shows of other genres
+		shows = flt.Shows(shows)
+		for _, item := range shows {
+			stopOnError(out.Put(show.Kind, item.ID, item))
+		}
+		total += len(shows)
+		crawler.Infof("Genre pages retried %d, shows loaded %d", len(list), len(shows))
+		errs = append(errs, serrs...)
+	}
+
+	if list := fails.List(stageDetails); len(list) > 0 {
+		q, err := openDetailsQueue(outDir, out, flt)
+		stopOnError(err)
+		defer q.Close()
+
+		done, stop := notifyStop("Stopping details loading, the progress is saved")
+		defer stop()
+
+		shows := make([]*show.Show, 0, len(list))
+		for _, item := range list {
+			crawler.Retry(item.ID)
+			shows = append(shows, &show.Show{ID: item.ID})
+		}
+		shows = flt.Shows(shows)
+		loaded, derrs := fetchDetails(shows, th, flt, q, out, fails, done)
+		crawler.Infof("Details retried %d, loaded %d", len(shows), loaded)
+		errs = append(errs, derrs...)
+		total += loaded
+	}
+
+	if list := fails.List(stagePages); len(list) > 0 {
+		shows := make([]*show.Show, 0, len(list))
+		for _, item := range list {
+			crawler.Retry(item.ID)
+			shows = append(shows, &show.Show{ID: item.ID, URL: item.URL})
+		}
+		loaded, perrs := fetchPages(shows, delay, out, fails)
+		crawler.Infof("Pages retried %d, loaded %d", len(shows), loaded)
+		errs = append(errs, perrs...)
+		total += loaded
+	}
+
+	if list := fails.List(stageFeed); len(list) > 0 {
+		details := make([]*show.ShowDetails, 0, len(list))
+		for _, item := range list {
+			if flt.AllowsID(item.ID) {
+				crawler.Retry(item.ID)
+				details = append(details, &show.ShowDetails{ID: item.ID, RSS: item.URL})
+			}
+		}
+		loaded, ferrs := fetchFeeds(details, getCachedFeeds(out), out, fails)
+		crawler.Infof("Feeds retried %d, loaded %d", len(details), loaded)
+		errs = append(errs, ferrs...)
+		total += loaded
+	}
+
+	crawler.Infof("Failures left %d", fails.Len())
+	return total, finishStage(errs, out, fails)
+}
+
+func getFailureURLs(list []*failures.Failure) []string {
+	urls := make([]string, 0, len(list))
+	for _, item := range list {
+		urls = append(urls, item.URL)
+	}
+	return urls
+}
+
+// hasBudgetError reports whether the request budget is exhausted, the stage is stopped then
+func hasBudgetError(errs []error) bool {
+	for _, err := range errs {
+		if crawler.IsBudgetError(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func addFailure(fails *failures.File, stage string, id int, url string, err error) {
+	fails.Add(stage, id, url, crawler.GetErrorClass(err), err)
+}
+
+// finishStage flushes the store and saves the failures file, so failed items are kept even if the stage stops on errors
+func finishStage(errs []error, out static.Store, fails *failures.File) []error {
+	if err := out.Flush(); err != nil {
 		errs = append(errs, err)
 	}
-	stopOnErrors(errs)
+	if err := fails.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
 }
 
-func actionCompact(src string, out string) {
-	file := path.Join(src, "genres.json")
-	genres, err := genre.GetGenresFromFile(file)
+func actionCompact(src static.Store, out static.Store, fo *filterOptions) (int, []error) {
+	genres, err := genre.Load(src)
 	stopOnError(err)
 
-	file = path.Join(src, "shows.details.json")
-	details, err := show.GetShowDetailsFromFile(file)
+	flt, err := fo.getShowFilter(genres)
 	stopOnError(err)
 
-	file = path.Join(src, "shows.feed.json")
-	feeds, err := show.GetShowFeedsFromFile(file)
+	details, err := show.LoadDetails(src)
 	stopOnError(err)
 
-	file = path.Join(src, "shows.json")
-	shows, err := show.GetShowsFromFile(file)
+	feeds, err := show.LoadFeeds(src)
+	stopOnError(err)
+
+	shows, err := show.LoadShows(src)
 	stopOnError(err)
 
 	genPair := getGenresMap(genres)
@@ -105,6 +563,9 @@ func actionCompact(src string, out string) {
 
 	res := make([]*CompactShow, 0, len(shows))
 	for _, show := range shows {
+		if !flt.AllowsDetails(show.ID, detPair[show.ID]) {
+			continue
+		}
 		com := NewCompactShow(show)
 		if !com.SetFromDetails(detPair, genPair) {
 			continue
@@ -113,5 +574,203 @@ func actionCompact(src string, out string) {
 		res = append(res, com)
 	}
 
-	SaveCompactShows(path.Join(out, "shows.compact.json"), res)
+	err = SaveCompactShows(out, res)
+	stopOnError(err)
+	crawler.Infof("Compact shows saved %d", len(res))
+	return len(res), []error{}
+}
+
+// migratedKinds are kinds of files which are migrated in folders, other files are migrated only when given explicitly
+var migratedKinds = []string{genre.Kind, show.Kind, show.DetailsKind, show.FeedKind, show.PagesKind, CompactKind, "itupod"}
+
+func actionMigrate(paths []string, generator string, country string) {
+	crawler.Infof("Starting migration to schema %d", static.SchemaVersion)
+	if len(paths) == 0 {
+		stopOnError(errors.New("File path is missing"))
+	}
+
+	files := []string{}
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err != nil || !stat.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found, err := getMigratedFiles(path)
+		stopOnError(err)
+		files = append(files, found...)
+	}
+
+	errs := []error{}
+	for _, path := range files {
+		from, err := static.MigrateFile(path, generator, country)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "Cannot migrate %s", path))
+		} else if from == static.SchemaVersion {
+			crawler.Infof("File is current %s", path)
+		} else {
+			crawler.Infof("File is migrated %s (schema %d to %d)", path, from, static.SchemaVersion)
+		}
+	}
+	stopOnItemErrors(len(files)-len(errs), errs)
+}
+
+// getMigratedFiles returns store files and queue logs in the folder
+func getMigratedFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot read folder")
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		name := static.TrimCompressionExt(entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, ".queue.log") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+			continue
+		}
+		for _, kind := range migratedKinds {
+			if name == kind+".json" || name == kind+".jsonl" || name == kind+".log" {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func actionCheckSelectors(rs *rules.Rules) {
+	crawler.Infof("Starting selectors check")
+	errs := []error{}
+	for _, res := range rules.Check(rs) {
+		embedded := "no script"
+		if res.EmbeddedStatus != "" {
+			embedded = fmt.Sprintf("%d %s", res.Embedded, res.EmbeddedStatus)
+		}
+		level := crawler.LevelInfo
+		if res.Status != rules.CheckOK {
+			level = crawler.LevelWarn
+		}
+		crawler.Emit(&crawler.Event{
+			Level:   level,
+			Message: fmt.Sprintf("Rule %s is %s (selector: %d %s, embedded: %s)", res.Entity, res.Status, res.Matched, res.SelectorStatus, embedded),
+			URL:     res.URL,
+		})
+		if res.Error != nil {
+			errs = append(errs, errors.Wrapf(res.Error, "Rule %s failed on %s", res.Entity, res.URL))
+		} else if res.Status != rules.CheckOK {
+			errs = append(errs, errors.Errorf("Rule %s is %s on %s", res.Entity, res.Status, res.URL))
+		}
+	}
+	stopOnErrors(errs)
+}
+
+func actionLookup(refs []string, feed bool, th *crawler.Throttle, to string, lines bool) {
+	crawler.Infof("Starting lookup of %d references", len(refs))
+	shows, feeds, errs := show.GetShowRefs(refs)
+
+	res, err := newLookupOutput(to, lines || strings.HasSuffix(static.TrimCompressionExt(to), ".jsonl"))
+	stopOnError(err)
+
+	details := []*show.ShowDetails{}
+	if len(shows) > 0 {
+		opt := show.GetDetailsRequestOptions(shows, th.Delay())
+		opt.Throttle = th
+		show.StreamDetails(opt, func(det *show.DetailsResult) {
+			if det.Error != nil {
+				errs = append(errs, det.Error)
+				return
+			}
+			details = append(details, det.Details)
+			if !feed {
+				if err := res.Write(det.Details); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		})
+	}
+
+	if feed {
+		for _, url := range feeds {
+			details = append(details, &show.ShowDetails{RSS: url})
+		}
+		show.StreamFeed(details, func(fee *show.FeedResult) {
+			if fee.Error == nil {
+				fee.Error = res.Write(fee.Feed)
+			}
+			if fee.Error != nil {
+				errs = append(errs, fee.Error)
+			}
+		})
+	} else if len(feeds) > 0 {
+		errs = append(errs, errors.New("Feed URLs can be looked up only with feed flag"))
+	}
+
+	if err = res.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	stopOnItemErrors(res.written, errs)
+}
+
+// lookupOutput writes lookup results either as the JSON array or as JSON Lines as they arrive
+type lookupOutput struct {
+	out   io.Writer
+	file  io.WriteCloser
+	lines bool
+	items []interface{}
+	// written is the number of results written to the output
+	written int
+}
+
+func newLookupOutput(to string, lines bool) (*lookupOutput, error) {
+	res := &lookupOutput{out: os.Stdout, lines: lines, items: []interface{}{}}
+	if to == "" {
+		return res, nil
+	}
+
+	file, err := static.Create(to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot create lookup file")
+	}
+	res.out = file
+	res.file = file
+	return res, nil
+}
+
+func (o *lookupOutput) Write(item interface{}) error {
+	if !o.lines {
+		o.items = append(o.items, item)
+		return nil
+	}
+	if err := o.writeJSON(item); err != nil {
+		return err
+	}
+	o.written++
+	return nil
+}
+
+func (o *lookupOutput) Close() error {
+	var err error
+	if !o.lines {
+		// the array is written at once, so results are counted only when it is written
+		if err = o.writeJSON(o.items); err == nil {
+			o.written = len(o.items)
+		}
+	}
+	if o.file != nil {
+		if cerr := o.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (o *lookupOutput) writeJSON(data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = o.out.Write(append(body, '\n'))
+	return err
 }
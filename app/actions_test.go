@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordScrapeFailures(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "actions.test")
+	defer os.RemoveAll(dir)
+
+	fails, _ := failures.Open(filepath.Join(dir, failures.FileName))
+	ids := map[string]int{"http://x.com/genre/arts/id1301": 1301, "http://x.com/genre/kids/id1305": 1305}
+
+	err := &crawler.ScrapeError{URL: "http://x.com/genre/arts/id1301", Err: &crawler.HTTPStatusError{Code: 500}}
+	assert.False(t, recordScrapeFailures(fails, stageShows, []error{err, errors.New("ID cannot be parsed")}, ids))
+	list := fails.List(stageShows)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 1301, list[0].ID)
+	assert.Equal(t, "http://x.com/genre/arts/id1301", list[0].URL)
+	assert.Equal(t, crawler.ErrorClassHTTPStatus, list[0].Class)
+
+	// the loaded page is resolved along with the failure of the other one
+	fails.Add(stageShows, 1305, "http://x.com/genre/kids/id1305", crawler.ErrorClassTimeout, errors.New("Request timed out"))
+	assert.False(t, recordScrapeFailures(fails, stageShows, []error{err}, ids))
+	assert.Len(t, fails.List(stageShows), 1)
+
+	assert.True(t, recordScrapeFailures(fails, stageShows, []error{}, ids))
+	assert.Equal(t, 0, fails.Len())
+}
+
+func TestActionRetryFailedShows(t *testing.T) {
+	kids := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/genre/podcasts-arts/id1301", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<div id="selectedcontent"><div class="column"><a href="http://x.com/podcast/one/id11">One</a></div></div>`))
+	})
+	mux.HandleFunc("/genre/podcasts-kids/id1305", func(w http.ResponseWriter, r *http.Request) {
+		if !kids {
+			w.WriteHeader(500)
+			return
+		}
+		w.Write([]byte(`<div id="selectedcontent"><div class="column"><a href="http://x.com/podcast/two/id12">Two</a></div></div>`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir, _ := ioutil.TempDir("", "actions.test")
+	defer os.RemoveAll(dir)
+
+	genres, _ := static.OpenStore(&static.StoreOptions{Format: "json", Dir: dir})
+	genre.Save(genres, []*genre.Genre{
+		genre.NewGenre(1301, ts.URL+"/genre/podcasts-arts/id1301", "Arts"),
+		genre.NewGenre(1305, ts.URL+"/genre/podcasts-kids/id1305", "Kids"),
+	})
+	genres.Close()
+
+	out, _ := static.OpenStore(&static.StoreOptions{Format: "json", Dir: dir})
+	fails, _ := failures.Open(filepath.Join(dir, failures.FileName))
+	fails.Add(stageShows, 1301, ts.URL+"/genre/podcasts-arts/id1301", crawler.ErrorClassTimeout, errors.New("Request timed out"))
+
+	// shows of arts are saved although the page of kids fails, only the failed page is kept
+	loaded, errs := actionShows(filepath.Join(dir, genre.Kind+".json"), rules.Default(), newFilterOptions(config.New()), out, fails)
+	assert.Equal(t, 1, loaded)
+	assert.Len(t, errs, 1)
+	ids, _ := out.List(show.Kind)
+	assert.Equal(t, []int{11}, ids)
+	list := fails.List(stageShows)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 1305, list[0].ID)
+	assert.Equal(t, crawler.ErrorClassHTTPStatus, list[0].Class)
+
+	// the retried page completes the shows
+	kids = true
+	loaded, errs = actionRetryFailed(rules.Default(), 0, crawler.NewThrottle(0, 0, 0), newFilterOptions(config.New()), dir, out, fails)
+	assert.Equal(t, 1, loaded)
+	assert.Len(t, errs, 0)
+	assert.Equal(t, 0, fails.Len())
+	ids, _ = out.List(show.Kind)
+	assert.ElementsMatch(t, []int{11, 12}, ids)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("No space left on device")
+}
+
+func TestLookupOutputWritten(t *testing.T) {
+	// results which cannot be written are not counted
+	for _, lines := range []bool{true, false} {
+		res := &lookupOutput{out: failingWriter{}, lines: lines, items: []interface{}{}}
+		// lines fail on write, the array fails on close
+		werr := res.Write(&show.Feed{ID: 1})
+		cerr := res.Close()
+		assert.Equal(t, lines, werr != nil)
+		assert.Equal(t, !lines, cerr != nil)
+		assert.Equal(t, 0, res.written)
+	}
+
+	res := &lookupOutput{out: ioutil.Discard, lines: true, items: []interface{}{}}
+	assert.Nil(t, res.Write(&show.Feed{ID: 1}))
+	assert.Nil(t, res.Close())
+	assert.Equal(t, 1, res.written)
+}
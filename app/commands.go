@@ -0,0 +1,757 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/limiter"
+	"github.com/zhikiri/itunes.podcasts/app/lock"
+	"github.com/zhikiri/itunes.podcasts/app/metrics"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// command is the subcommand of the CLI, init registers flags of the command and returns the function which runs it.
+// The error returned by the run function is the usage error, other errors stop the process on their own.
+type command struct {
+	name    string
+	args    string
+	desc    string
+	minArgs int
+	// maxArgs is -1 when the number of arguments is not limited
+	maxArgs int
+	// countries is set when the command runs for every country of the config
+	countries bool
+	// requests is set when the command sends requests, they are counted by the shared limiter
+	requests bool
+	init     func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error
+}
+
+var commands = []*command{
+	{
+		name:     stageGenres,
+		requests: true,
+		desc:     "load the list of genres",
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			rf := addRulesFlag(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := sf.validate(); err != nil {
+					return err
+				}
+				rs := loadRules(*rf)
+				if *dry {
+					actionDryRun(&pipelineOptions{stages: []string{stageGenres}, rules: rs}, sf.options(), args, false)
+					return nil
+				}
+				opt, out, fails := openStage(fs, stageGenres, sf, args)
+				stopOnItemErrors(actionGenres(rs, opt.Country, out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:     stageShows,
+		requests: true,
+		args:     "PATH_TO_GENRES",
+		desc:     "load the list of shows of genres from the genres file",
+		minArgs:  1,
+		maxArgs:  1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			rf := addRulesFlag(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := sf.validate(); err != nil {
+					return err
+				}
+				rs := loadRules(*rf)
+				if *dry {
+					popt := &pipelineOptions{stages: []string{stageShows}, rules: rs, filter: newFilterOptions(cfg)}
+					actionDryRun(popt, sf.options(), args, false)
+					return nil
+				}
+				_, out, fails := openStage(fs, stageShows, sf, args)
+				stopOnItemErrors(actionShows(args[0], rs, newFilterOptions(cfg), out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:     stageDetails,
+		requests: true,
+		args:     "PATH_TO_SHOWS",
+		desc:     "load the chunk of show details from the lookup API",
+		minArgs:  1,
+		maxArgs:  1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			lf := addLoadFlags(fs)
+			tf := addThrottleFlags(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := validateAll(sf.validate, lf.validate, tf.validate); err != nil {
+					return err
+				}
+				if *dry {
+					actionDryRun(lf.pipelineOptions(stageDetails, cfg), sf.options(), args, false)
+					return nil
+				}
+				opt, out, fails := openStage(fs, stageDetails, sf, args)
+				stopOnItemErrors(actionDetails(args[0], tf.throttle(*lf.delay), *lf.chunk, false, newFilterOptions(cfg), opt.Dir, out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:     stagePages,
+		requests: true,
+		args:     "PATH_TO_SHOWS",
+		desc:     "scrape the chunk of Apple show pages",
+		minArgs:  1,
+		maxArgs:  1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			lf := addLoadFlags(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := validateAll(sf.validate, lf.validate); err != nil {
+					return err
+				}
+				if *dry {
+					actionDryRun(lf.pipelineOptions(stagePages, cfg), sf.options(), args, false)
+					return nil
+				}
+				_, out, fails := openStage(fs, stagePages, sf, args)
+				stopOnItemErrors(actionPages(args[0], *lf.delay, *lf.chunk, out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:     stageFeed,
+		requests: true,
+		args:     "PATH_TO_DETAILS",
+		desc:     "load RSS feeds of shows from the details file",
+		minArgs:  1,
+		maxArgs:  1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			ttl := fs.Duration("ttl", 24*time.Hour, "skip feeds fetched within the given time")
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := sf.validate(); err != nil {
+					return err
+				}
+				if *ttl < 0 {
+					return usageErrorf("Invalid value of -ttl flag: %s, it cannot be negative", *ttl)
+				}
+				if *dry {
+					popt := &pipelineOptions{stages: []string{stageFeed}, ttl: *ttl, filter: newFilterOptions(cfg)}
+					actionDryRun(popt, sf.options(), args, false)
+					return nil
+				}
+				_, out, fails := openStage(fs, stageFeed, sf, args)
+				stopOnItemErrors(actionFeed(args[0], *ttl, newFilterOptions(cfg), out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:    stageCompact,
+		args:    "[DIR]",
+		desc:    "generate the compact list of shows from files of the folder (the output folder by default)",
+		maxArgs: 1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := sf.validate(); err != nil {
+					return err
+				}
+				if *dry {
+					actionDryRun(&pipelineOptions{stages: []string{stageCompact}}, sf.options(), args, false)
+					return nil
+				}
+				opt, out, _ := openStage(fs, stageCompact, sf, args)
+
+				src, dir := out, opt.Dir
+				if len(args) > 0 && filepath.Clean(args[0]) != filepath.Clean(opt.Dir) {
+					dir = args[0]
+					srcOpt := *opt
+					srcOpt.Dir = dir
+					var err error
+					src, err = static.OpenStore(&srcOpt)
+					stopOnError(err)
+				}
+				// source files are read in the format and compression they have on disk, not the output ones
+				source := static.OpenSource(dir, src)
+				loaded, errs := actionCompact(source, out, newFilterOptions(cfg))
+				stopOnError(source.Close())
+				stopOnItemErrors(loaded, errs)
+				if src != out {
+					stopOnError(src.Close())
+				}
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:     stageRetry,
+		requests: true,
+		desc:     "load again items of the failures file of the output folder",
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			rf := addRulesFlag(fs)
+			delay := fs.Int("delay", 5, "delay between requests in seconds")
+			tf := addThrottleFlags(fs)
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := validateAll(sf.validate, tf.validate); err != nil {
+					return err
+				}
+				if *delay < 0 {
+					return usageErrorf("Invalid value of -delay flag: %d, it cannot be negative", *delay)
+				}
+				if *dry {
+					popt := &pipelineOptions{stages: []string{stageRetry}, delay: *delay, filter: newFilterOptions(cfg)}
+					actionDryRun(popt, sf.options(), args, false)
+					return nil
+				}
+				opt, out, fails := openStage(fs, stageRetry, sf, args)
+				stopOnItemErrors(actionRetryFailed(loadRules(*rf), *delay, tf.throttle(*delay), newFilterOptions(cfg), opt.Dir, out, fails))
+				closeStage(out)
+				return nil
+			}
+		},
+	},
+	{
+		name:      "run",
+		requests:  true,
+		countries: true,
+		desc:      "run genres, shows, details, feed and compact stages one after another, stages with fresh outputs are skipped",
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			lf := addLoadFlags(fs)
+			tf := addThrottleFlags(fs)
+			rf := addRulesFlag(fs)
+			ttl := fs.Duration("ttl", 24*time.Hour, "skip feeds fetched within the given time")
+			fresh := fs.Duration("fresh", 24*time.Hour, "skip stages whose outputs are produced within the given time (0 runs every stage)")
+			from := fs.String("from", stageGenres, "first stage to run: "+strings.Join(pipelineStages, ", "))
+			until := fs.String("until", stageCompact, "last stage to run: "+strings.Join(pipelineStages, ", "))
+			dry := addDryRunFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				if err := validateAll(sf.validate, lf.validate, tf.validate); err != nil {
+					return err
+				}
+				if *ttl < 0 || *fresh < 0 {
+					return usageErrorf("Flags -ttl and -fresh cannot be negative")
+				}
+				stages, err := getPipelineStages(*from, *until)
+				if err != nil {
+					return err
+				}
+
+				popt := &pipelineOptions{
+					stages:   stages,
+					rules:    loadRules(*rf),
+					delay:    *lf.delay,
+					minDelay: *tf.min,
+					maxDelay: *tf.max,
+					chunk:    *lf.chunk,
+					ttl:      *ttl,
+					fresh:    *fresh,
+					filter:   newFilterOptions(cfg),
+				}
+
+				// every country of the config is crawled to its own subfolder of the output folder
+				code := exitOK
+				for _, country := range getCountries(cfg, *sf.country) {
+					opt := sf.options()
+					opt.Country = country
+					if len(cfg.Countries) > 1 {
+						opt.Dir = filepath.Join(opt.Dir, country)
+						stopOnError(errors.Wrap(os.MkdirAll(opt.Dir, 0755), "Cannot create output folder"))
+						crawler.Infof("Starting country %s", country)
+					}
+					if *dry {
+						actionDryRun(popt, opt, nil, true)
+						continue
+					}
+					out, fails := openOutput(opt, *sf.wait)
+					code = getWorstCode(code, actionRun(fs, popt, opt, out, fails))
+				}
+				if code != exitOK {
+					exit(code)
+				}
+				return nil
+			}
+		},
+	},
+	{
+		name:      "daemon",
+		countries: true,
+		desc:      "run stages on the schedule of the config until interrupted: genres weekly, shows daily, details continuously and feeds hourly",
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			sf := addStoreFlags(fs)
+			addr := fs.String("status", "localhost:8090", "address of the listener serving the status of jobs at /status, empty disables it")
+			return func(args []string, cfg *config.Config) error {
+				if err := sf.validate(); err != nil {
+					return err
+				}
+				return actionDaemon(fs, cfg, *sf.out, *addr)
+			}
+		},
+	},
+	{
+		name:     "lookup",
+		requests: true,
+		args:     "[REFERENCE...]",
+		desc:     "lookup details or feed of show IDs, Apple URLs or feed URLs from arguments or stdin",
+		maxArgs:  -1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			feed := fs.Bool("feed", false, "lookup feeds instead of details")
+			delay := fs.Int("delay", 5, "delay between requests in seconds")
+			tf := addThrottleFlags(fs)
+			to := fs.String("to", "", "results file (stdout by default)")
+			format := fs.String("format", "json", "results format: json or jsonl")
+			return func(args []string, cfg *config.Config) error {
+				if err := tf.validate(); err != nil {
+					return err
+				}
+				if *format != "json" && *format != "jsonl" {
+					return usageErrorf("Invalid value of -format flag: %s, expected one of: json, jsonl", *format)
+				}
+				if *delay < 0 {
+					return usageErrorf("Invalid value of -delay flag: %d, it cannot be negative", *delay)
+				}
+				actionLookup(getRefs(args), *feed, tf.throttle(*delay), *to, *format == "jsonl")
+				return nil
+			}
+		},
+	},
+	{
+		name:    "migrate",
+		args:    "PATH...",
+		desc:    "upgrade files or files of folders to the current schema",
+		minArgs: 1,
+		maxArgs: -1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			country := fs.String("country", "ua", "country written to headers of migrated files")
+			return func(args []string, cfg *config.Config) error {
+				actionMigrate(args, getGenerator(), *country)
+				return nil
+			}
+		},
+	},
+	{
+		name:    "verify",
+		args:    "DIR",
+		desc:    "verify checksums and consistency of files of the folder",
+		minArgs: 1,
+		maxArgs: 1,
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			return func(args []string, cfg *config.Config) error {
+				actionVerify(args[0])
+				return nil
+			}
+		},
+	},
+	{
+		name:     "check-selectors",
+		requests: true,
+		desc:     "check extraction rules against sample pages",
+		init: func(fs *flag.FlagSet) func(args []string, cfg *config.Config) error {
+			rf := addRulesFlag(fs)
+			return func(args []string, cfg *config.Config) error {
+				actionCheckSelectors(loadRules(*rf))
+				return nil
+			}
+		},
+	},
+}
+
+func getCommand(name string) (*command, bool) {
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+func newFlagSet(cmd *command) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.String("config", "", "config file of the crawl profile, flags and "+config.EnvPrefix+"* environment variables override it")
+	fs.String("log-format", crawler.FormatText, "format of progress events written to stderr: "+strings.Join(crawler.Formats, ", "))
+	fs.String("log-level", crawler.LevelInfo, "minimal level of progress events: "+strings.Join(crawler.Levels, ", "))
+	if cmd.requests {
+		fs.String("limiter", limiter.DefaultPath, "state file of the rate limiter and budgets shared by itupod processes, empty disables it")
+		fs.String("metrics", "", "address of the listener serving Prometheus metrics at /metrics while the command runs, e.g. :9090")
+	}
+	fs.Usage = func() {
+		out := fs.Output()
+		usage := strings.TrimSpace(fmt.Sprintf("itupod %s [flags] %s", cmd.name, cmd.args))
+		fmt.Fprintf(out, "Usage: %s\n\n%s\n", usage, strings.ToUpper(cmd.desc[:1])+cmd.desc[1:])
+		if hasFlags(fs) {
+			fmt.Fprintln(out, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// validateArgs checks the number of command arguments
+func validateArgs(cmd *command, args []string) error {
+
+	if len(args) < cmd.minArgs {
+		return usageErrorf("Missing arguments: %s", cmd.args)
+	}
+	if cmd.maxArgs >= 0 && len(args) > cmd.maxArgs {
+		return usageErrorf("Unexpected arguments: %s", strings.Join(args[cmd.maxArgs:], " "))
+	}
+	return nil
+}
+
+func printUsage(out io.Writer) {
+
+	fmt.Fprintln(out, "Usage: itupod COMMAND [flags] [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.desc)
+	}
+	fmt.Fprintf(out, "  %-16s %s\n", "version", "print the version")
+	fmt.Fprintln(out, "\nRun 'itupod help COMMAND' for flags of the command.")
+}
+
+// usageError is the invalid usage of the command, e.g. the invalid flag value
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+func validateAll(validators ...func() error) error {
+
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeFlags are flags of commands which write to the output folder
+type storeFlags struct {
+	out         *string
+	format      *string
+	compression *string
+	country     *string
+	wait        *time.Duration
+}
+
+func addStoreFlags(fs *flag.FlagSet) *storeFlags {
+
+	return &storeFlags{
+		out:         fs.String("out", "/tmp", "generated files folder"),
+		format:      fs.String("store", "json", "store format: "+strings.Join(static.Formats, ", ")),
+		compression: fs.String("compress", "", "compress generated files: "+strings.Join(static.Compressions, " or ")),
+		country:     fs.String("country", "ua", "country of the Apple podcasts top"),
+		wait:        fs.Duration("wait", 0, "wait for the output folder locked by another run (fail immediately by default)"),
+	}
+}
+
+func (f *storeFlags) validate() error {
+
+	if !contains(static.Formats, *f.format) {
+		return usageErrorf("Invalid value of -store flag: %s, expected one of: %s", *f.format, strings.Join(static.Formats, ", "))
+	}
+	if *f.compression != static.CompressNone && !contains(static.Compressions, *f.compression) {
+		return usageErrorf("Invalid value of -compress flag: %s, expected one of: %s", *f.compression, strings.Join(static.Compressions, ", "))
+	}
+	if *f.format == "log" && *f.compression != static.CompressNone {
+		return usageErrorf("Flag -compress is not supported by log store")
+	}
+	if *f.country == "" {
+		return usageErrorf("Flag -country cannot be empty")
+	}
+	if *f.wait < 0 {
+		return usageErrorf("Invalid value of -wait flag: %s, it cannot be negative", *f.wait)
+	}
+	return nil
+}
+
+func (f *storeFlags) options() *static.StoreOptions {
+
+	return &static.StoreOptions{
+		Format:      *f.format,
+		Dir:         *f.out,
+		Compression: *f.compression,
+		Generator:   getGenerator(),
+		Country:     *f.country,
+	}
+}
+
+// loadFlags are flags of commands which load items by chunks
+type loadFlags struct {
+	delay *int
+	chunk *int
+}
+
+func addLoadFlags(fs *flag.FlagSet) *loadFlags {
+
+	return &loadFlags{
+		delay: fs.Int("delay", 5, "delay between requests in seconds"),
+		chunk: fs.Int("chunk", 100, "number of items loaded by the run"),
+	}
+}
+
+// throttleFlags are bounds of the adaptive delay of lookup requests, -delay is the initial delay
+type throttleFlags struct {
+	min *time.Duration
+	max *time.Duration
+}
+
+func addThrottleFlags(fs *flag.FlagSet) *throttleFlags {
+
+	return &throttleFlags{
+		min: fs.Duration("min-delay", time.Second, "minimal delay between lookup requests, the delay decreases while requests succeed"),
+		max: fs.Duration("max-delay", time.Minute, "maximal delay between lookup requests, the delay doubles when requests are rate limited"),
+	}
+}
+
+func (f *throttleFlags) validate() error {
+
+	if *f.min < 0 {
+		return usageErrorf("Invalid value of -min-delay flag: %s, it cannot be negative", *f.min)
+	}
+	if *f.max < *f.min {
+		return usageErrorf("Flag -max-delay %s cannot be less than -min-delay %s", *f.max, *f.min)
+	}
+	return nil
+}
+
+// throttle returns the throttle starting with the delay in seconds
+func (f *throttleFlags) throttle(delay int) *crawler.Throttle {
+
+	return crawler.NewThrottle(time.Duration(delay)*time.Second, *f.min, *f.max)
+}
+
+// pipelineOptions returns options of the single loading stage
+func (f *loadFlags) pipelineOptions(stage string, cfg *config.Config) *pipelineOptions {
+
+	return &pipelineOptions{stages: []string{stage}, delay: *f.delay, chunk: *f.chunk, filter: newFilterOptions(cfg)}
+}
+
+func (f *loadFlags) validate() error {
+
+	if *f.delay < 0 {
+		return usageErrorf("Invalid value of -delay flag: %d, it cannot be negative", *f.delay)
+	}
+	if *f.chunk <= 0 {
+		return usageErrorf("Invalid value of -chunk flag: %d, it must be positive", *f.chunk)
+	}
+	return nil
+}
+
+func addRulesFlag(fs *flag.FlagSet) *string {
+
+	return fs.String("rules", "", "extraction rules file (default rules are compiled into the binary)")
+}
+
+func loadRules(path string) *rules.Rules {
+
+	if path == "" {
+		return rules.Default()
+	}
+	rs, err := rules.Load(path)
+	stopOnError(err)
+	return rs
+}
+
+// openStage opens the output of the stage, produced files are recorded to the manifest on exit
+func openStage(fs *flag.FlagSet, stage string, sf *storeFlags, inputs []string) (*static.StoreOptions, static.Store, *failures.File) {
+
+	opt := sf.options()
+	out, fails := openOutput(opt, *sf.wait)
+	current = newStageRun(fs, stage, inputs, opt, out, fails)
+	return opt, out, fails
+}
+
+// openOutput locks the output folder and opens the store and the failures file
+func openOutput(opt *static.StoreOptions, wait time.Duration) (static.Store, *failures.File) {
+
+	// the lock is held until the process exits, so the manifest is written under the lock too
+	lk, err := lock.Acquire(opt.Dir, wait)
+	stopOnError(err)
+	locks = append(locks, lk)
+
+	out, err := static.OpenStore(opt)
+	stopOnError(err)
+
+	fails, err := failures.Open(filepath.Join(opt.Dir, failures.FileName))
+	stopOnError(err)
+	return out, fails
+}
+
+// loadProfile loads the config file of -config flag or the environment variable, config values and environment
+// variables are applied to flags which are not set explicitly, the environment variable goes first
+func loadProfile(cmd *command, fs *flag.FlagSet) (*config.Config, error) {
+
+	path := fs.Lookup("config").Value.String()
+	if path == "" {
+		path = os.Getenv(config.EnvFile)
+	}
+
+	cfg := config.New()
+	if path != "" {
+		var err error
+		if cfg, err = config.Load(path); err != nil {
+			return nil, &usageError{err.Error()}
+		}
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { explicit[fl.Name] = true })
+
+	values := cfg.Values()
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		if explicit[fl.Name] || fl.Name == "config" || err != nil {
+			return
+		}
+		name := config.GetEnvName(fl.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			explicit[fl.Name] = true
+			if serr := fs.Set(fl.Name, value); serr != nil {
+				err = usageErrorf("Invalid value of %s environment variable: %s", name, serr)
+			}
+		} else if value, ok := values[fl.Name]; ok {
+			if serr := fs.Set(fl.Name, value); serr != nil {
+				err = usageErrorf("Config %s: key %q: %s", path, fl.Name, serr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the country of the flag or the environment variable selects the single country of the config
+	if explicit["country"] {
+		cfg.Countries = []string{fs.Lookup("country").Value.String()}
+	}
+	if len(cfg.Countries) > 1 && !cmd.countries && fs.Lookup("country") != nil {
+		return nil, usageErrorf("Config %s has several countries, select one with -country flag", path)
+	}
+	return cfg, nil
+}
+
+// setupProgress writes progress events in the format and the level of flags
+func setupProgress(fs *flag.FlagSet) error {
+
+	p, err := crawler.NewProgress(os.Stderr, fs.Lookup("log-format").Value.String(), fs.Lookup("log-level").Value.String())
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+	crawler.SetProgress(p)
+	return nil
+}
+
+// setupMetrics serves metrics collected from progress events when the address of -metrics flag is set
+func setupMetrics(fs *flag.FlagSet) error {
+
+	fl := fs.Lookup("metrics")
+	if fl == nil || fl.Value.String() == "" {
+		return nil
+	}
+	collector := metrics.NewCollector(metrics.NewRegistry())
+	addr, err := metrics.Listen(fl.Value.String(), collector.Registry())
+	if err != nil {
+		return err
+	}
+	crawler.SetProgress(crawler.MultiProgress(crawler.GetProgress(), collector))
+	crawler.Infof("Metrics are served at http://%s/metrics", addr)
+	return nil
+}
+
+// setupLimiter shares rate limits and request budgets of the config between processes through the limiter file
+func setupLimiter(fs *flag.FlagSet, cfg *config.Config) {
+
+	fl := fs.Lookup("limiter")
+	if fl == nil || fl.Value.String() == "" {
+		return
+	}
+	budgets := make(map[string]limiter.Budget, len(cfg.Budgets))
+	for host, budget := range cfg.Budgets {
+		budgets[host] = limiter.Budget{Hourly: budget.Hourly, Daily: budget.Daily}
+	}
+	crawler.SetSharedLimiter(limiter.New(fl.Value.String(), budgets))
+}
+
+// getCountries returns countries of the config or the country of the flag
+func getCountries(cfg *config.Config, country string) []string {
+
+	if len(cfg.Countries) > 1 {
+		return cfg.Countries
+	}
+	return []string{country}
+}
+
+// getWorstCode returns the exit code of the most severe failure
+func getWorstCode(a int, b int) int {
+
+	if a == exitFailure || b == exitFailure {
+		return exitFailure
+	}
+	if a == exitPartial || b == exitPartial {
+		return exitPartial
+	}
+	return exitOK
+}
+
+func closeStage(out static.Store) {
+
+	stopOnError(errors.Wrap(out.Close(), "Cannot close store"))
+}
+
+func contains(list []string, value string) bool {
+
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/lock"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCommand(t *testing.T) {
+	for _, name := range []string{"genres", "shows", "details", "pages", "feed", "compact", "retry-failed", "lookup", "daemon"} {
+		cmd, ok := getCommand(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, name, cmd.name)
+	}
+
+	_, ok := getCommand("help")
+	assert.False(t, ok)
+}
+
+func TestValidateArgs(t *testing.T) {
+	shows, _ := getCommand("shows")
+	assert.Equal(t, "Missing arguments: PATH_TO_GENRES", validateArgs(shows, []string{}).Error())
+	assert.Equal(t, "Unexpected arguments: b c", validateArgs(shows, []string{"a", "b", "c"}).Error())
+	assert.Nil(t, validateArgs(shows, []string{"a"}))
+
+	lookup, _ := getCommand("lookup")
+	assert.Nil(t, validateArgs(lookup, []string{}))
+	assert.Nil(t, validateArgs(lookup, []string{"1", "2", "3"}))
+}
+
+func TestStoreFlagsValidate(t *testing.T) {
+	cases := map[string][]string{
+		"": {},
+		"Invalid value of -store flag: xml, expected one of: json, jsonl, log": {"-store", "xml"},
+		"Invalid value of -compress flag: lz4, expected one of: gzip, zstd":    {"-compress", "lz4"},
+		"Flag -compress is not supported by log store":                         {"-store", "log", "-compress", "gzip"},
+		"Flag -country cannot be empty":                                        {"-country", ""},
+		"Invalid value of -wait flag: -1s, it cannot be negative":              {"-wait", "-1s"},
+	}
+	for msg, args := range cases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		sf := addStoreFlags(fs)
+		assert.Nil(t, fs.Parse(args))
+
+		err := sf.validate()
+		if msg == "" {
+			assert.Nil(t, err)
+			continue
+		}
+		assert.IsType(t, &usageError{}, err)
+		assert.Equal(t, msg, err.Error())
+	}
+}
+
+func TestLoadFlagsValidate(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	lf := addLoadFlags(fs)
+	assert.Nil(t, lf.validate())
+
+	fs.Parse([]string{"-chunk", "0"})
+	assert.NotNil(t, lf.validate())
+
+	fs.Parse([]string{"-chunk", "1", "-delay", "-1"})
+	assert.NotNil(t, lf.validate())
+}
+
+func TestCommandUsage(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd, _ := getCommand("genres")
+	fs := newFlagSet(cmd)
+	fs.SetOutput(buf)
+	cmd.init(fs)
+	fs.Usage()
+	assert.Contains(t, buf.String(), "Usage: itupod genres [flags]\n\nLoad the list of genres\n")
+	assert.Contains(t, buf.String(), "-rules")
+
+	buf.Reset()
+	printUsage(buf)
+	assert.Contains(t, buf.String(), "check-selectors")
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "commands.test")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profile.json")
+	ioutil.WriteFile(path, []byte(`{"out": "/data", "store": "jsonl", "delay": 5, "countries": ["us", "gb"]}`), 0644)
+
+	newRun := func(name string, args ...string) (*flag.FlagSet, *config.Config, error) {
+		cmd, _ := getCommand(name)
+		fs := newFlagSet(cmd)
+		cmd.init(fs)
+		fs.Parse(append([]string{"-config", path}, args...))
+		cfg, err := loadProfile(cmd, fs)
+		return fs, cfg, err
+	}
+
+	// flags go first, then environment variables, then the config
+	os.Setenv("ITUPOD_STORE", "json")
+	defer os.Unsetenv("ITUPOD_STORE")
+	fs, cfg, err := newRun("run", "-delay", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/data", fs.Lookup("out").Value.String())
+	assert.Equal(t, "json", fs.Lookup("store").Value.String())
+	assert.Equal(t, "1", fs.Lookup("delay").Value.String())
+	assert.Equal(t, []string{"us", "gb"}, cfg.Countries)
+
+	_, _, err = newRun("details")
+	assert.IsType(t, &usageError{}, err)
+
+	_, cfg, err = newRun("details", "-country", "gb")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"gb"}, cfg.Countries)
+
+	os.Setenv("ITUPOD_DELAY", "soon")
+	defer os.Unsetenv("ITUPOD_DELAY")
+	_, _, err = newRun("details", "-country", "gb")
+	assert.Contains(t, err.Error(), "ITUPOD_DELAY")
+}
+
+func TestGetWorstCode(t *testing.T) {
+	assert.Equal(t, exitOK, getWorstCode(exitOK, exitOK))
+	assert.Equal(t, exitPartial, getWorstCode(exitOK, exitPartial))
+	assert.Equal(t, exitFailure, getWorstCode(exitFailure, exitPartial))
+}
+
+func TestThrottleFlagsValidate(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	tf := addThrottleFlags(fs)
+	assert.Nil(t, tf.validate())
+	assert.Equal(t, 5*time.Second, tf.throttle(5).Delay())
+	assert.Equal(t, time.Second, tf.throttle(0).Delay())
+
+	fs.Parse([]string{"-min-delay", "10s", "-max-delay", "5s"})
+	assert.NotNil(t, tf.validate())
+
+	fs.Parse([]string{"-min-delay", "-1s"})
+	assert.NotNil(t, tf.validate())
+}
+
+func TestLimiterFlag(t *testing.T) {
+	details, _ := getCommand("details")
+	fs := newFlagSet(details)
+	assert.NotNil(t, fs.Lookup("limiter"))
+	assert.Nil(t, fs.Parse([]string{"-limiter", ""}))
+
+	// the empty path disables the shared limiter
+	setupLimiter(fs, config.New())
+
+	verify, _ := getCommand("verify")
+	assert.Nil(t, newFlagSet(verify).Lookup("limiter"))
+}
+
+func TestSetupProgress(t *testing.T) {
+	cmd, _ := getCommand("verify")
+	fs := newFlagSet(cmd)
+	assert.Nil(t, fs.Parse([]string{"-log-format", "json", "-log-level", "warn"}))
+	assert.Nil(t, setupProgress(fs))
+
+	assert.Nil(t, fs.Parse([]string{"-log-level", "verbose"}))
+	assert.IsType(t, &usageError{}, setupProgress(fs))
+
+	assert.Nil(t, fs.Parse([]string{"-log-format", "text", "-log-level", "info"}))
+	assert.Nil(t, setupProgress(fs))
+}
+
+func TestSetupMetrics(t *testing.T) {
+	cmd, _ := getCommand("feed")
+	fs := newFlagSet(cmd)
+	assert.Nil(t, setupMetrics(fs))
+
+	defer crawler.SetProgress(crawler.GetProgress())
+	assert.Nil(t, fs.Parse([]string{"-metrics", "127.0.0.1:0"}))
+	assert.Nil(t, setupMetrics(fs))
+
+	assert.Nil(t, fs.Parse([]string{"-metrics", "127.0.0.1:-1"}))
+	assert.NotNil(t, setupMetrics(fs))
+}
+
+func TestOpenOutputKeepsLock(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "commands.test")
+	defer os.RemoveAll(dir)
+	defer func() {
+		for _, lk := range locks {
+			lk.Release()
+		}
+		locks = nil
+	}()
+
+	out, _ := openOutput(&static.StoreOptions{Format: "json", Dir: dir, Country: "us"}, 0)
+	defer out.Close()
+
+	// the lock is not released when the GC finalizes unreferenced files
+	runtime.GC()
+	runtime.GC()
+	_, err := lock.Acquire(dir, 0)
+	assert.NotNil(t, err)
+}
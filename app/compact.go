@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"strconv"
 
 	"github.com/zhikiri/itunes.podcasts/app/genre"
@@ -9,6 +8,9 @@ import (
 	"github.com/zhikiri/itunes.podcasts/app/static"
 )
 
+// CompactKind is the store kind of the compact shows
+const CompactKind = "shows.compact"
+
 type feedsMap = map[int]*show.Feed
 type detailsMap = map[int]*show.ShowDetails
 type genresMap = map[int]*genre.Genre
@@ -52,6 +54,9 @@ func getDetailsMap(details []*show.ShowDetails) detailsMap {
 func getFeedsMap(feeds []*show.Feed) feedsMap {
 	res := make(map[int]*show.Feed, len(feeds))
 	for _, feed := range feeds {
+		if !feed.IsFetched() {
+			continue
+		}
 		res[feed.ID] = feed
 	}
 	return res
@@ -104,9 +109,15 @@ func (c *CompactShow) SetFromDetails(list detailsMap, gen genresMap) bool {
 	return true
 }
 
-// SaveCompactShows saves the compact show information into the file
-func SaveCompactShows(path string, shows []*CompactShow) error {
-	return static.Save(path, func() ([]byte, error) {
-		return json.Marshal(shows)
-	})
+// SaveCompactShows saves the compact show information into the store
+func SaveCompactShows(store static.Store, shows []*CompactShow) error {
+	if err := store.Clear(CompactKind); err != nil {
+		return err
+	}
+	for _, show := range shows {
+		if err := store.Put(CompactKind, show.ID, show); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
 }
@@ -46,6 +46,7 @@ func TestGetFeedsMap(t *testing.T) {
 		&show.Feed{ID: 1, Description: "1"},
 		&show.Feed{ID: 2, Description: "2"},
 		&show.Feed{ID: 3, Description: "3"},
+		&show.Feed{ID: 4, LastError: "Unreachable URL"},
 	})
 	assert.Len(t, res, 3)
 	for id := range res {
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the latest supported version of the config file
+const Version = 1
+
+// EnvFile is the environment variable with the config file path, it is used when -config flag is not set
+const EnvFile = "ITUPOD_CONFIG"
+
+// EnvPrefix is the prefix of environment variables which override config keys, e.g. ITUPOD_OUT
+const EnvPrefix = "ITUPOD_"
+
+// Config is the crawl profile, keys which are not set keep flag defaults
+type Config struct {
+	Version    int               `json:"version"`
+	Out        string            `json:"out"`
+	Store      string            `json:"store"`
+	Compress   string            `json:"compress"`
+	Countries  []string          `json:"countries"`
+	Rules      string            `json:"rules"`
+	Delay      *int              `json:"delay"`
+	Chunk      *int              `json:"chunk"`
+	TTL        string            `json:"ttl"`
+	Fresh      string            `json:"fresh"`
+	Wait       string            `json:"wait"`
+	MinDelay   string            `json:"min_delay"`
+	MaxDelay   string            `json:"max_delay"`
+	RateLimits map[string]string `json:"rate_limits"`
+	Genres     Filter            `json:"genres"`
+	Shows      IDFilter          `json:"shows"`
+	Limiter    *string           `json:"limiter"`
+	LogFormat  string            `json:"log_format"`
+	LogLevel   string            `json:"log_level"`
+	Metrics    string            `json:"metrics"`
+	Schedule   map[string]string `json:"schedule"`
+	Budgets    map[string]Budget `json:"budgets"`
+}
+
+// Budget is the maximal number of requests to the host per hour and per day, zero is unlimited
+type Budget struct {
+	Hourly int `json:"hourly"`
+	Daily  int `json:"daily"`
+}
+
+// Filter selects entities by ID or name, excluded entities are dropped even if they are included
+type Filter struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// IDFilter is the allowlist and the blocklist of IDs, the blocklist wins
+type IDFilter struct {
+	Include []int `json:"include"`
+	Exclude []int `json:"exclude"`
+}
+
+// KeyError is the invalid key of the config file
+type KeyError struct {
+	Path string
+	Key  string
+	Msg  string
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("Config %s: key %q: %s", e.Path, e.Key, e.Msg)
+}
+
+var countryPattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// indexPattern matches array indexes of the decoder field path, e.g. .0 of shows.exclude.0
+var indexPattern = regexp.MustCompile(`\.(\d+)(\.|$)`)
+
+var unknownKeyPattern = regexp.MustCompile(`^json: unknown field "(.+)"$`)
+
+// New returns the empty config, it changes nothing
+func New() *Config {
+
+	return &Config{Version: Version}
+}
+
+// Load reads and validates the config file
+func Load(path string) (*Config, error) {
+
+	cfg := New()
+	err := static.Load(path, func(body []byte) error {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		return dec.Decode(cfg)
+	})
+	if err != nil {
+		return nil, getDecodeError(path, err)
+	}
+
+	if err = cfg.Validate(path); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks values of the config, the error points at the invalid key
+func (c *Config) Validate(path string) error {
+
+	keyErr := func(key string, format string, args ...interface{}) error {
+		return &KeyError{Path: path, Key: key, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if c.Version > Version {
+		return keyErr("version", "version %d is not supported, latest is %d", c.Version, Version)
+	}
+	if c.Store != "" && !contains(static.Formats, c.Store) {
+		return keyErr("store", "%s is not one of: %s", c.Store, strings.Join(static.Formats, ", "))
+	}
+	if c.Compress != "" && !contains(static.Compressions, c.Compress) {
+		return keyErr("compress", "%s is not one of: %s", c.Compress, strings.Join(static.Compressions, ", "))
+	}
+	if c.LogFormat != "" && !contains(crawler.Formats, c.LogFormat) {
+		return keyErr("log_format", "%s is not one of: %s", c.LogFormat, strings.Join(crawler.Formats, ", "))
+	}
+	if c.LogLevel != "" && !contains(crawler.Levels, c.LogLevel) {
+		return keyErr("log_level", "%s is not one of: %s", c.LogLevel, strings.Join(crawler.Levels, ", "))
+	}
+	if c.Store == "log" && c.Compress != "" {
+		return keyErr("compress", "compression is not supported by log store")
+	}
+	for i, country := range c.Countries {
+		if !countryPattern.MatchString(country) {
+			return keyErr(fmt.Sprintf("countries[%d]", i), "%q is not the two-letter lowercase country code", country)
+		}
+	}
+	if c.Delay != nil && *c.Delay < 0 {
+		return keyErr("delay", "it cannot be negative")
+	}
+	if c.Chunk != nil && *c.Chunk <= 0 {
+		return keyErr("chunk", "it must be positive")
+	}
+
+	durations := map[string]string{"ttl": c.TTL, "fresh": c.Fresh, "wait": c.Wait, "min_delay": c.MinDelay, "max_delay": c.MaxDelay}
+	for host, limit := range c.RateLimits {
+		if host == "" {
+			return keyErr("rate_limits", "host cannot be empty")
+		}
+		durations["rate_limits."+host] = limit
+	}
+	for job, every := range c.Schedule {
+		durations["schedule."+job] = every
+	}
+	for key, value := range durations {
+		if value == "" && !strings.HasPrefix(key, "rate_limits.") && !strings.HasPrefix(key, "schedule.") {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil {
+			return keyErr(key, "%q is not the duration, e.g. 30s or 24h", value)
+		} else if d < 0 {
+			return keyErr(key, "it cannot be negative")
+		} else if d == 0 && strings.HasPrefix(key, "schedule.") {
+			return keyErr(key, "it must be positive")
+		}
+	}
+
+	for i, value := range c.Genres.Include {
+		if strings.TrimSpace(value) == "" {
+			return keyErr(fmt.Sprintf("genres.include[%d]", i), "genre ID or name cannot be empty")
+		}
+	}
+	for i, value := range c.Genres.Exclude {
+		if strings.TrimSpace(value) == "" {
+			return keyErr(fmt.Sprintf("genres.exclude[%d]", i), "genre ID or name cannot be empty")
+		}
+	}
+	for i, id := range c.Shows.Include {
+		if id <= 0 {
+			return keyErr(fmt.Sprintf("shows.include[%d]", i), "show ID must be positive")
+		}
+	}
+	for i, id := range c.Shows.Exclude {
+		if id <= 0 {
+			return keyErr(fmt.Sprintf("shows.exclude[%d]", i), "show ID must be positive")
+		}
+	}
+	for host, budget := range c.Budgets {
+		if host == "" {
+			return keyErr("budgets", "host cannot be empty")
+		}
+		if budget.Hourly < 0 {
+			return keyErr("budgets."+host+".hourly", "it cannot be negative")
+		}
+		if budget.Daily < 0 {
+			return keyErr("budgets."+host+".daily", "it cannot be negative")
+		}
+	}
+	return nil
+}
+
+// Values returns config values by names of flags, the country is set only when the config has the single one
+func (c *Config) Values() map[string]string {
+
+	res := map[string]string{}
+	set := func(name string, value string) {
+		if value != "" {
+			res[name] = value
+		}
+	}
+
+	set("out", c.Out)
+	set("store", c.Store)
+	set("compress", c.Compress)
+	set("rules", c.Rules)
+	set("ttl", c.TTL)
+	set("fresh", c.Fresh)
+	set("wait", c.Wait)
+	set("min-delay", c.MinDelay)
+	set("max-delay", c.MaxDelay)
+	set("log-format", c.LogFormat)
+	set("log-level", c.LogLevel)
+	set("metrics", c.Metrics)
+	if c.Limiter != nil {
+		// the empty path disables the shared limiter, so it is kept
+		res["limiter"] = *c.Limiter
+	}
+	if len(c.Countries) == 1 {
+		set("country", c.Countries[0])
+	}
+	if c.Delay != nil {
+		set("delay", strconv.Itoa(*c.Delay))
+	}
+	if c.Chunk != nil {
+		set("chunk", strconv.Itoa(*c.Chunk))
+	}
+	return res
+}
+
+// GetRateLimits returns minimal intervals between requests per host
+func (c *Config) GetRateLimits() map[string]time.Duration {
+
+	res := make(map[string]time.Duration, len(c.RateLimits))
+	for host, limit := range c.RateLimits {
+		// limits are validated on load
+		res[host], _ = time.ParseDuration(limit)
+	}
+	return res
+}
+
+// GetEnvName returns the environment variable of the flag, e.g. ITUPOD_OUT for -out
+func GetEnvName(flag string) string {
+
+	return EnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// getDecodeError points the decoding error at the key of the config
+func getDecodeError(path string, err error) error {
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch cause := errors.Cause(err); {
+	case errors.As(cause, &typeErr):
+		key := typeErr.Field
+		if !strings.HasPrefix(key, "rate_limits.") && !strings.HasPrefix(key, "budgets.") {
+			key = indexPattern.ReplaceAllString(key, "[$1]$2")
+		}
+		return &KeyError{Path: path, Key: key, Msg: fmt.Sprintf("%s value cannot be used as %s", typeErr.Value, typeErr.Type)}
+	case errors.As(cause, &syntaxErr):
+		return errors.Errorf("Config %s: invalid JSON at offset %d: %s", path, syntaxErr.Offset, syntaxErr)
+	case unknownKeyPattern.MatchString(cause.Error()):
+		key := unknownKeyPattern.FindStringSubmatch(cause.Error())[1]
+		return &KeyError{Path: path, Key: key, Msg: "unknown key"}
+	}
+	return errors.Wrap(err, "Cannot load config")
+}
+
+func contains(list []string, value string) bool {
+
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T, body string) (string, func()) {
+
+	dir, _ := ioutil.TempDir("", "config.test")
+	path := filepath.Join(dir, "prod.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(body), 0644))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoad(t *testing.T) {
+
+	path, clean := writeTestConfig(t, `{
+		"version": 1,
+		"out": "/data/itupod",
+		"store": "jsonl",
+		"compress": "zstd",
+		"countries": ["us"],
+		"delay": 3,
+		"chunk": 500,
+		"ttl": "12h",
+		"min_delay": "500ms",
+		"rate_limits": {"itunes.apple.com": "3s", "podcasts.apple.com": "500ms"},
+		"genres": {"include": ["1301", "Comedy"], "exclude": ["Kids & Family"]},
+		"shows": {"exclude": [1200361736]},
+		"limiter": "",
+		"log_format": "json",
+		"budgets": {"itunes.apple.com": {"hourly": 1000, "daily": 20000}}
+	}`)
+	defer clean()
+
+	cfg, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1301", "Comedy"}, cfg.Genres.Include)
+	assert.Equal(t, []string{"Kids & Family"}, cfg.Genres.Exclude)
+	assert.Equal(t, []int{1200361736}, cfg.Shows.Exclude)
+	assert.Equal(t, map[string]Budget{"itunes.apple.com": {Hourly: 1000, Daily: 20000}}, cfg.Budgets)
+	assert.Equal(t, map[string]string{
+		"out":        "/data/itupod",
+		"store":      "jsonl",
+		"compress":   "zstd",
+		"country":    "us",
+		"delay":      "3",
+		"chunk":      "500",
+		"ttl":        "12h",
+		"min-delay":  "500ms",
+		"limiter":    "",
+		"log-format": "json",
+	}, cfg.Values())
+	assert.Equal(t, map[string]time.Duration{
+		"itunes.apple.com":   3 * time.Second,
+		"podcasts.apple.com": 500 * time.Millisecond,
+	}, cfg.GetRateLimits())
+}
+
+func TestLoadErrors(t *testing.T) {
+
+	cases := map[string]string{
+		`{"dealy": 3}`:     `key "dealy": unknown key`,
+		`{"chunk": "100"}`: `key "chunk": string value cannot be used as int`,
+		`{"rate_limits": {"itunes.apple.com": 3}}`:           `key "rate_limits.itunes.apple.com": number value cannot be used as string`,
+		`{"store": "xml"}`:                                   `key "store": xml is not one of: json, jsonl, log`,
+		`{"store": "log", "compress": "gzip"}`:               `key "compress": compression is not supported by log store`,
+		`{"countries": ["us", "USA"]}`:                       `key "countries[1]": "USA" is not the two-letter lowercase country code`,
+		`{"chunk": 0}`:                                       `key "chunk": it must be positive`,
+		`{"delay": -1}`:                                      `key "delay": it cannot be negative`,
+		`{"ttl": "1 day"}`:                                   `key "ttl": "1 day" is not the duration, e.g. 30s or 24h`,
+		`{"rate_limits": {"itunes.apple.com": "x"}}`:         `key "rate_limits.itunes.apple.com": "x" is not the duration, e.g. 30s or 24h`,
+		`{"genres": {"exclude": ["Arts", " "]}}`:             `key "genres.exclude[1]": genre ID or name cannot be empty`,
+		`{"shows": {"include": [1, -5]}}`:                    `key "shows.include[1]": show ID must be positive`,
+		`{"shows": {"exclude": ["1"]}}`:                      `key "shows.exclude[0]": string value cannot be used as int`,
+		`{"budgets": {"itunes.apple.com": {"daily": -1}}}`:   `key "budgets.itunes.apple.com.daily": it cannot be negative`,
+		`{"budgets": {"itunes.apple.com": {"hourly": "1"}}}`: `key "budgets.itunes.apple.com.hourly": string value cannot be used as int`,
+		`{"log_level": "trace"}`:                             `key "log_level": trace is not one of: debug, info, warn, error`,
+		`{"schedule": {"feed": "0s"}}`:                       `key "schedule.feed": it must be positive`,
+		`{"version": 2}`:                                     `key "version": version 2 is not supported, latest is 1`,
+		`{"out": "/tmp",}`:                                   `invalid JSON at offset`,
+	}
+	for body, msg := range cases {
+		path, clean := writeTestConfig(t, body)
+		_, err := Load(path)
+		if assert.NotNil(t, err, body) {
+			assert.Contains(t, err.Error(), "Config "+path+": ")
+			assert.Contains(t, err.Error(), msg)
+		}
+		clean()
+	}
+
+	_, err := Load("/not/existing/config.json")
+	assert.NotNil(t, err)
+}
+
+func TestValues(t *testing.T) {
+
+	assert.Equal(t, map[string]string{}, New().Values())
+
+	cfg := &Config{Countries: []string{"us", "gb"}}
+	assert.Equal(t, map[string]string{}, cfg.Values())
+}
+
+func TestGetEnvName(t *testing.T) {
+
+	assert.Equal(t, "ITUPOD_OUT", GetEnvName("out"))
+	assert.Equal(t, "ITUPOD_RETRY_AFTER", GetEnvName("retry-after"))
+}
@@ -1,9 +1,11 @@
 package crawler
 
 import (
+	"net/http"
+	"regexp"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/gocolly/colly"
 	"github.com/pkg/errors"
@@ -12,6 +14,10 @@ import (
 type ScraperOptions struct {
 	LookupURL []string
 	Pattern   string
+	Attribute string
+	IDPattern *regexp.Regexp
+	Script    string
+	Match     *regexp.Regexp
 }
 
 type ScrapeResult struct {
@@ -21,9 +27,10 @@ type ScrapeResult struct {
 
 func GetScraperOptions(url []string, pattern string) *ScraperOptions {
 
-	return &ScraperOptions{url, pattern}
+	return &ScraperOptions{LookupURL: url, Pattern: pattern}
 }
 
+// ScrapeEntities returns entities of the pages loaded successfully along with errors of the failed pages
 func ScrapeEntities(opt *ScraperOptions) (map[string]string, []error) {
 
 	var wg sync.WaitGroup
@@ -34,7 +41,11 @@ func ScrapeEntities(opt *ScraperOptions) (map[string]string, []error) {
 
 		go func(url string) {
 
-			resCh <- getEntitiesFromHTML(url, opt.Pattern)
+			res := getEntitiesFromHTML(url, opt)
+			for i, err := range res.Errors {
+				res.Errors[i] = &ScrapeError{URL: url, Err: err}
+			}
+			resCh <- res
 			wg.Done()
 		}(url)
 	}
@@ -61,34 +72,122 @@ func ScrapeEntities(opt *ScraperOptions) (map[string]string, []error) {
 	return res, err
 }
 
-func getEntitiesFromHTML(url string, pattern string) *ScrapeResult {
+func getEntitiesFromHTML(url string, opt *ScraperOptions) *ScrapeResult {
 
 	errs := []error{}
 	res := map[string]string{}
+	scripts := []string{}
+
+	attr := opt.Attribute
+	if attr == "" {
+		attr = "href"
+	}
 
 	col := colly.NewCollector()
-	col.OnHTML(pattern, func(el *colly.HTMLElement) {
-		res[el.Text] = el.Attr("href")
+	col.OnHTML(opt.Pattern, func(el *colly.HTMLElement) {
+		res[el.Text] = el.Attr(attr)
 	})
 
+	if opt.Script != "" {
+		col.OnHTML(opt.Script, func(el *colly.HTMLElement) {
+			scripts = append(scripts, el.Text)
+		})
+	}
+
 	col.OnError(func(resp *colly.Response, err error) {
-		// todo : wrap error
-		errs = append(errs, err)
+		errs = append(errs, getScrapeError(url, resp, err))
 	})
-	col.Visit(url)
+	if err := waitHost(url, 0); err != nil {
+		return &ScrapeResult{res, []error{err}}
+	}
+	visit(col, url, func() error {
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
+
+	if len(errs) > 0 {
+		return &ScrapeResult{res, errs}
+	}
+
+	embedded, err := GetEmbeddedEntities(scripts, opt.Match)
+	if err != nil {
+		Emit(&Event{Level: LevelDebug, Message: "Embedded data is not usable, fallback to selectors", URL: url, Err: err})
+	} else if len(embedded) > 0 {
+		return &ScrapeResult{embedded, errs}
+	}
+
+	// the empty page is the error of the embedded mode only, selectors alone keep reporting empty results
+	if len(res) == 0 && opt.Script != "" {
+		errs = append(errs, errors.Errorf("No entities found at URL: %s", url))
+	}
 
 	return &ScrapeResult{res, errs}
 }
 
-func GetEntityIDFromURL(url string) (int, error) {
+// getScrapeError returns the typed error of the failed page request
+func getScrapeError(url string, resp *colly.Response, err error) error {
+
+	if resp != nil && resp.StatusCode >= 300 {
+		var header http.Header
+		if resp.Headers != nil {
+			header = *resp.Headers
+		}
+		return newStatusError(url, resp.StatusCode, header)
+	}
+	return newRequestError(url, err)
+}
 
-	parts := strings.Split(url, "/")
-	last := parts[len(parts)-1]
+// GetEntityIDByPattern parses the ID from the first group of the pattern, URLs are parsed without the pattern
+func GetEntityIDByPattern(url string, pattern *regexp.Regexp) (int, error) {
 
-	id, err := strconv.Atoi(strings.TrimPrefix(last, "id"))
+	if pattern == nil {
+		return GetEntityIDFromURL(url)
+	}
+
+	match := pattern.FindStringSubmatch(url)
+	if len(match) < 2 {
+		return 0, errors.Errorf("ID cannot be parsed from URL: %s", url)
+	}
+
+	id, err := strconv.Atoi(match[1])
 	if err != nil {
 		return 0, errors.Wrapf(err, "ID cannot be parsed from URL: %s", url)
 	}
 
 	return id, nil
 }
+
+func GetEntityIDFromURL(url string) (int, error) {
+
+	parsed, err := ParseURL(url)
+	if err != nil {
+		return 0, err
+	}
+
+	return parsed.ID, nil
+}
+
+// visit scrapes the URL and emits the response, failed returns the error reported by handlers of the collector
+func visit(col *colly.Collector, url string, failed func() error) error {
+
+	status := 0
+	col.OnResponse(func(resp *colly.Response) {
+		status = resp.StatusCode
+	})
+	col.OnError(func(resp *colly.Response, err error) {
+		if resp != nil {
+			status = resp.StatusCode
+		}
+	})
+
+	started := time.Now()
+	err := col.Visit(url)
+	res := failed()
+	if res == nil {
+		res = err
+	}
+	Response(url, status, time.Since(started), res)
+	return err
+}
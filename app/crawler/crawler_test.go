@@ -3,6 +3,7 @@ package crawler
 import (
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -28,6 +29,27 @@ func newTestServer() *httptest.Server {
 		`))
 	})
 
+	mux.HandleFunc("/embedded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte(`<!DOCTYPE html>
+<html>
+<body>
+<script type="application/json" id="serialized-server-data">
+[{"items": [{"title": "embedded #1", "url": "http://x.com/podcast/embedded/id4"}]}]
+</script>
+<a class="target" href="http://x.com/podcasts-test1-first/id1">link #1</a>
+</body>
+</html>
+		`))
+	})
+
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte("<p>nothing</p>"))
+	})
+
 	mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(404)
@@ -63,12 +85,60 @@ func TestGetEntityURLs(t *testing.T) {
 		assert.Equal(t, url, entities[name])
 	}
 
-	_, err := ScrapeEntities(&ScraperOptions{
+	entities, err := ScrapeEntities(&ScraperOptions{
 		LookupURL: []string{ts.URL + "/404"},
 		Pattern:   ".target",
 	})
 	assert.NotEmpty(t, err)
-	assert.Equal(t, "Not Found", err[0].Error())
+	assert.Empty(t, entities)
+	assert.Equal(t, ErrorClassNotFound, GetErrorClass(err[0]))
+	assert.Equal(t, ts.URL+"/404", GetScrapeURL(err[0]))
+	assert.Equal(t, "", GetScrapeURL(errors.New("Cannot scrape")))
+
+	// entities of the loaded page are returned along with the error of the failed one
+	entities, err = ScrapeEntities(&ScraperOptions{
+		LookupURL: []string{ts.URL, ts.URL + "/404"},
+		Pattern:   ".target",
+	})
+	assert.Len(t, err, 1)
+	assert.Equal(t, mocked, entities)
+}
+
+func TestGetEmbeddedEntityURLs(t *testing.T) {
+
+	ts := newTestServer()
+	defer ts.Close()
+
+	opt := &ScraperOptions{
+		LookupURL: []string{ts.URL + "/embedded"},
+		Pattern:   ".target",
+		Script:    EmbeddedScript,
+		Match:     regexp.MustCompile(`/podcast/[^/]+/id\d+`),
+	}
+	entities, err := ScrapeEntities(opt)
+	assert.Empty(t, err)
+	assert.Equal(t, map[string]string{"embedded #1": "http://x.com/podcast/embedded/id4"}, entities)
+
+	opt.Match = regexp.MustCompile(`/genre/[^/]+/id\d+`)
+	entities, err = ScrapeEntities(opt)
+	assert.Empty(t, err)
+	assert.Equal(t, map[string]string{"link #1": "http://x.com/podcasts-test1-first/id1"}, entities)
+
+	opt.Match = nil
+	opt.Attribute = "class"
+	entities, _ = ScrapeEntities(opt)
+	assert.Equal(t, map[string]string{"link #1": "target"}, entities)
+
+	opt.LookupURL = []string{ts.URL + "/empty"}
+	_, err = ScrapeEntities(opt)
+	assert.NotEmpty(t, err)
+	assert.Equal(t, "No entities found at URL: "+ts.URL+"/empty", err[0].Error())
+
+	// the empty page is not the error without the script
+	opt.Script = ""
+	entities, err = ScrapeEntities(opt)
+	assert.Empty(t, err)
+	assert.Empty(t, entities)
 }
 
 func TestGetEntityIDFromURL(t *testing.T) {
@@ -82,5 +152,19 @@ func TestGetEntityIDFromURL(t *testing.T) {
 	assert.Nil(t, err)
 
 	_, err = GetEntityIDFromURL("http://x.x/a/idd")
-	assert.Equal(t, "strconv.Atoi: parsing \"d\": invalid syntax", errors.Cause(err).Error())
+	assert.Equal(t, "ID cannot be parsed from URL: http://x.x/a/idd", err.Error())
+}
+
+func TestGetEntityIDByPattern(t *testing.T) {
+
+	id, err := GetEntityIDByPattern("http://x.x/a/id1", nil)
+	assert.Equal(t, 1, id)
+	assert.Nil(t, err)
+
+	id, err = GetEntityIDByPattern("http://x.x/show-42/details", regexp.MustCompile(`show-(\d+)`))
+	assert.Equal(t, 42, id)
+	assert.Nil(t, err)
+
+	_, err = GetEntityIDByPattern("http://x.x/show/details", regexp.MustCompile(`show-(\d+)`))
+	assert.Equal(t, "ID cannot be parsed from URL: http://x.x/show/details", err.Error())
 }
@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EmbeddedScript matches the script tags where podcasts.apple.com keeps the serialized page state
+const EmbeddedScript = "script#serialized-server-data, script[id^=shoebox-media-api-cache]"
+
+// embeddedURLKeys are keys of nested descriptors which keep links of the server data, checked in the order
+var embeddedURLKeys = []string{"contentDescriptor", "clickAction", "destination"}
+
+// GetEmbeddedEntities walks the embedded JSON documents and collects every object
+// that has an URL matching the pattern along with a title or a name
+func GetEmbeddedEntities(scripts []string, match *regexp.Regexp) (map[string]string, error) {
+
+	res := map[string]string{}
+	if len(scripts) == 0 || match == nil {
+		return res, nil
+	}
+
+	decoded := 0
+	for _, script := range scripts {
+		data, ok := decodeEmbedded(html.UnescapeString(strings.TrimSpace(script)))
+		if !ok {
+			continue
+		}
+		decoded++
+		walkEmbedded(data, match, res)
+	}
+
+	if decoded == 0 {
+		return res, errors.New("Embedded data cannot be decoded")
+	}
+
+	return res, nil
+}
+
+func decodeEmbedded(src string) (interface{}, bool) {
+
+	if !strings.HasPrefix(src, "{") && !strings.HasPrefix(src, "[") {
+		return nil, false
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(src), &data); err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func walkEmbedded(node interface{}, match *regexp.Regexp, res map[string]string) {
+
+	switch val := node.(type) {
+	case []interface{}:
+		for _, item := range val {
+			walkEmbedded(item, match, res)
+		}
+	case map[string]interface{}:
+		if url, name := getEmbeddedEntity(val); url != "" && match.MatchString(url) {
+			if _, ok := res[name]; !ok {
+				res[name] = url
+			}
+		}
+		// keys are sorted, so the same name found twice resolves to the same URL in every run
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkEmbedded(val[key], match, res)
+		}
+	case string:
+		// some of the caches keep nested documents serialized into strings
+		if data, ok := decodeEmbedded(val); ok {
+			walkEmbedded(data, match, res)
+		}
+	}
+}
+
+func getEmbeddedEntity(obj map[string]interface{}) (string, string) {
+
+	url, _ := obj["url"].(string)
+	if url == "" {
+		// the server data keeps links in nested descriptors, e.g. contentDescriptor.url
+		for _, key := range embeddedURLKeys {
+			if desc, ok := obj[key].(map[string]interface{}); ok {
+				if url, _ = desc["url"].(string); url != "" {
+					break
+				}
+			}
+		}
+	}
+	if url == "" {
+		return "", ""
+	}
+
+	for _, key := range []string{"title", "name"} {
+		if name, ok := obj[key].(string); ok && name != "" {
+			return url, name
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var podcastMatch = regexp.MustCompile(`/podcast/[^/]+/id\d+`)
+
+const embeddedServerData = `[{"data": {"shelves": [{"items": [
+	{"title": "Show #1", "contentDescriptor": {"url": "http://x.com/us/podcast/show-1/id1"}},
+	{"title": "Show #2", "contentDescriptor": {"url": "http://x.com/us/podcast/show-2/id2"}},
+	{"title": "Arts", "contentDescriptor": {"url": "http://x.com/us/genre/arts/id3"}}
+]}]}}]`
+
+const embeddedShoebox = `{"cache": "{\"data\": [{\"attributes\": {\"name\": \"Show #3\", \"url\": \"http://x.com/us/podcast/show-3/id3\"}}]}"}`
+
+func TestGetEmbeddedEntities(t *testing.T) {
+
+	res, err := GetEmbeddedEntities([]string{embeddedServerData}, podcastMatch)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"Show #1": "http://x.com/us/podcast/show-1/id1",
+		"Show #2": "http://x.com/us/podcast/show-2/id2",
+	}, res)
+
+	res, err = GetEmbeddedEntities([]string{embeddedShoebox}, podcastMatch)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Show #3": "http://x.com/us/podcast/show-3/id3"}, res)
+
+	// descriptors are checked in the fixed order
+	ordered := `[{"title": "Show #4", "destination": {"url": "http://x.com/us/podcast/other/id5"},
+		"clickAction": {"url": "http://x.com/us/podcast/click/id6"}, "contentDescriptor": {"url": "http://x.com/us/podcast/show-4/id4"}}]`
+	for i := 0; i < 10; i++ {
+		res, err = GetEmbeddedEntities([]string{ordered}, podcastMatch)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"Show #4": "http://x.com/us/podcast/show-4/id4"}, res)
+	}
+
+	res, err = GetEmbeddedEntities([]string{}, podcastMatch)
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+
+	_, err = GetEmbeddedEntities([]string{"window.data = 1"}, podcastMatch)
+	assert.Equal(t, "Embedded data cannot be decoded", err.Error())
+}
@@ -0,0 +1,230 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ErrorClassHTTPStatus  = "http_status"
+	ErrorClassNotFound    = "not_found"
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassTimeout     = "timeout"
+	ErrorClassDNS         = "dns"
+	ErrorClassTLS         = "tls"
+	ErrorClassDecode      = "decode"
+	ErrorClassBudget      = "budget"
+	ErrorClassOther       = "other"
+)
+
+// HTTPStatusError represents the unexpected status code of the response
+type HTTPStatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Unreachable URL: %s (status %d)", e.URL, e.Code)
+}
+
+// NotFoundError represents the missing entity, either the URL responds with 404 or the lookup has no results
+type NotFoundError struct {
+	URL string
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("Entity is not found at URL: %s", e.URL)
+}
+
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// RateLimitedError represents the response with 429 or 403 status, which means that the host throttles or blocks requests
+type RateLimitedError struct {
+	URL        string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("Rate limited at URL: %s: %s", e.URL, e.Err)
+}
+
+func (e *RateLimitedError) Unwrap() error { return e.Err }
+
+// TimeoutError represents the request which is timed out
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Request timed out at URL: %s: %s", e.URL, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// DNSError represents the host of URL which cannot be resolved
+type DNSError struct {
+	URL string
+	Err error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("Host cannot be resolved for URL: %s: %s", e.URL, e.Err)
+}
+
+func (e *DNSError) Unwrap() error { return e.Err }
+
+// TLSError represents the failed TLS handshake or the invalid certificate
+type TLSError struct {
+	URL string
+	Err error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS connection failed for URL: %s: %s", e.URL, e.Err)
+}
+
+func (e *TLSError) Unwrap() error { return e.Err }
+
+// DecodeError represents the response body which cannot be decoded
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Cannot decode response of URL: %s: %s", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ScrapeError keeps the URL of the page which failed to scrape, the message is the one of the cause
+type ScrapeError struct {
+	URL string
+	Err error
+}
+
+func (e *ScrapeError) Error() string { return e.Err.Error() }
+
+func (e *ScrapeError) Unwrap() error { return e.Err }
+
+// GetScrapeURL returns the URL of the page the error of ScrapeEntities belongs to, it is empty for other errors
+func GetScrapeURL(err error) string {
+
+	var scrape *ScrapeError
+	if errors.As(err, &scrape) {
+		return scrape.URL
+	}
+	return ""
+}
+
+// BudgetError is returned instead of the request when the request budget of the host is exhausted
+type BudgetError struct {
+	Host string
+	// Window is either hour or day
+	Window string
+	Limit  int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("Budget of %s is exhausted: %d requests per %s", e.Host, e.Limit, e.Window)
+}
+
+// IsBudgetError checks that the request is not sent because of the exhausted budget
+func IsBudgetError(err error) bool {
+
+	var budget *BudgetError
+	return errors.As(err, &budget)
+}
+
+// GetErrorClass returns the class of the error, e.g. to group failures in reports
+func GetErrorClass(err error) string {
+
+	var rateLimited *RateLimitedError
+	var notFound *NotFoundError
+	var status *HTTPStatusError
+	var timeout *TimeoutError
+	var dns *DNSError
+	var tlsErr *TLSError
+	var decode *DecodeError
+	var budget *BudgetError
+
+	switch {
+	case errors.As(err, &rateLimited):
+		return ErrorClassRateLimited
+	case errors.As(err, &notFound):
+		return ErrorClassNotFound
+	case errors.As(err, &status):
+		return ErrorClassHTTPStatus
+	case errors.As(err, &timeout):
+		return ErrorClassTimeout
+	case errors.As(err, &dns):
+		return ErrorClassDNS
+	case errors.As(err, &tlsErr):
+		return ErrorClassTLS
+	case errors.As(err, &decode):
+		return ErrorClassDecode
+	case errors.As(err, &budget):
+		return ErrorClassBudget
+	}
+	return ErrorClassOther
+}
+
+// newStatusError returns the typed error of the response status code
+func newStatusError(url string, code int, header http.Header) error {
+
+	err := &HTTPStatusError{URL: url, Code: code}
+	switch code {
+	case http.StatusNotFound, http.StatusGone:
+		return &NotFoundError{URL: url, Err: err}
+	case http.StatusTooManyRequests, http.StatusForbidden:
+		return &RateLimitedError{URL: url, RetryAfter: getRetryAfter(header), Err: err}
+	}
+	return err
+}
+
+// newRequestError returns the typed error of the failed request, unknown errors are returned as is
+func newRequestError(url string, err error) error {
+
+	var dns *net.DNSError
+	var netErr net.Error
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var header tls.RecordHeaderError
+
+	switch {
+	case errors.As(err, &dns):
+		return &DNSError{URL: url, Err: err}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return &TimeoutError{URL: url, Err: err}
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname),
+		errors.As(err, &invalid), errors.As(err, &header):
+		return &TLSError{URL: url, Err: err}
+	}
+	return err
+}
+
+func getRetryAfter(header http.Header) time.Duration {
+
+	if header == nil {
+		return 0
+	}
+	sec, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || sec < 0 {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+func TestNewStatusError(t *testing.T) {
+
+	err := newStatusError("http://x", 500, nil)
+	assert.Equal(t, "Unreachable URL: http://x (status 500)", err.Error())
+	assert.Equal(t, ErrorClassHTTPStatus, GetErrorClass(err))
+
+	err = newStatusError("http://x", 404, nil)
+	var notFound *NotFoundError
+	assert.True(t, errors.As(errors.Wrap(err, "Cannot load"), &notFound))
+	assert.Equal(t, ErrorClassNotFound, GetErrorClass(err))
+
+	err = newStatusError("http://x", 429, http.Header{"Retry-After": []string{"30"}})
+	var rateLimited *RateLimitedError
+	assert.True(t, errors.As(err, &rateLimited))
+	assert.Equal(t, 30*time.Second, rateLimited.RetryAfter)
+	assert.Equal(t, ErrorClassRateLimited, GetErrorClass(err))
+
+	var status *HTTPStatusError
+	assert.True(t, errors.As(newStatusError("http://x", 403, nil), &status))
+	assert.Equal(t, 403, status.Code)
+}
+
+func TestNewRequestError(t *testing.T) {
+
+	err := newRequestError("http://x", &url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Name: "x"}}})
+	assert.Equal(t, ErrorClassDNS, GetErrorClass(err))
+
+	err = newRequestError("http://x", &url.Error{Op: "Get", URL: "http://x", Err: &timeoutError{}})
+	var timeout *TimeoutError
+	assert.True(t, errors.As(err, &timeout))
+	assert.Equal(t, ErrorClassTimeout, GetErrorClass(err))
+
+	err = newRequestError("http://x", &url.Error{Op: "Get", URL: "http://x", Err: x509.UnknownAuthorityError{}})
+	assert.Equal(t, ErrorClassTLS, GetErrorClass(err))
+
+	err = newRequestError("http://x", errors.New("Connection refused"))
+	assert.Equal(t, "Connection refused", err.Error())
+	assert.Equal(t, ErrorClassOther, GetErrorClass(err))
+}
+
+func TestGetErrorClass(t *testing.T) {
+
+	decodeErr := &DecodeError{URL: "http://x", Err: json.Unmarshal([]byte("{"), &struct{}{})}
+	assert.Equal(t, ErrorClassDecode, GetErrorClass(errors.Wrap(decodeErr, "Cannot decode")))
+	assert.Equal(t, ErrorClassNotFound, GetErrorClass(&NotFoundError{URL: "http://x"}))
+	assert.Equal(t, "Entity is not found at URL: http://x", (&NotFoundError{URL: "http://x"}).Error())
+	assert.Equal(t, ErrorClassOther, GetErrorClass(errors.New("Invalid entity detected")))
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// hostLimits keeps the minimal interval between requests per host, requests to other hosts are not limited
+var hostLimits = &hostLimiter{intervals: map[string]time.Duration{}, next: map[string]time.Time{}}
+
+// SharedLimiter books request slots of hosts for all processes of the machine, e.g. in the lock-protected file
+type SharedLimiter interface {
+	// Reserve books the next slot of the host at least interval after the previous one and returns the duration
+	// to wait for it, the BudgetError is returned when the budget of the host is exhausted
+	Reserve(host string, interval time.Duration, now time.Time) (time.Duration, error)
+}
+
+var shared SharedLimiter
+
+// SetSharedLimiter replaces the in-process limiter of hosts with the shared one, nil restores the in-process limiter
+func SetSharedLimiter(limiter SharedLimiter) {
+
+	hostLimits.mu.Lock()
+	defer hostLimits.mu.Unlock()
+
+	shared = limiter
+}
+
+type hostLimiter struct {
+	mu        sync.Mutex
+	intervals map[string]time.Duration
+	next      map[string]time.Time
+}
+
+// SetHostLimits sets the minimal interval between requests to the host, e.g. "itunes.apple.com" once per 3 seconds.
+// Limits are applied on top of the stage delay to every request, including concurrent feed requests.
+func SetHostLimits(limits map[string]time.Duration) {
+
+	hostLimits.mu.Lock()
+	defer hostLimits.mu.Unlock()
+
+	hostLimits.intervals = make(map[string]time.Duration, len(limits))
+	for host, interval := range limits {
+		hostLimits.intervals[host] = interval
+	}
+	hostLimits.next = map[string]time.Time{}
+}
+
+// waitHost blocks until the request to the host of the URL is allowed, the interval is the minimal one of the caller,
+// e.g. the delay of the throttle, the longer limit of the host wins
+func waitHost(rawurl string, interval time.Duration) error {
+
+	wait, err := hostLimits.reserve(rawurl, interval, time.Now())
+	if err == nil && wait > 0 {
+		Emit(&Event{Level: LevelDebug, Kind: EventWait, Message: "Waiting for the rate limit of the host " + wait.String(), URL: rawurl, Wait: wait})
+		time.Sleep(wait)
+	}
+	return err
+}
+
+// reserve books the next request slot of the host, returns the duration to wait for it
+func (l *hostLimiter) reserve(rawurl string, minInterval time.Duration, now time.Time) (time.Duration, error) {
+
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return 0, nil
+	}
+	host := parsed.Hostname()
+
+	l.mu.Lock()
+	interval := l.intervals[host]
+	if interval < minInterval {
+		interval = minInterval
+	}
+	limiter := shared
+	l.mu.Unlock()
+
+	// the shared limiter is consulted without the lock, it has the lock of its own
+	if limiter != nil {
+		return limiter.Reserve(host, interval, now)
+	}
+	return l.book(host, interval, now), nil
+}
+
+func (l *hostLimiter) book(host string, interval time.Duration, now time.Time) time.Duration {
+
+	if interval <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slot := now
+	if next, ok := l.next[host]; ok && next.After(now) {
+		slot = next
+	}
+	l.next[host] = slot.Add(interval)
+	return slot.Sub(now)
+}
+
+// GetHostLimits returns the minimal intervals between requests per host
+func GetHostLimits() map[string]time.Duration {
+
+	hostLimits.mu.Lock()
+	defer hostLimits.mu.Unlock()
+
+	res := make(map[string]time.Duration, len(hostLimits.intervals))
+	for host, interval := range hostLimits.intervals {
+		res[host] = interval
+	}
+	return res
+}
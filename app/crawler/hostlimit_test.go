@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reserveWait(rawurl string, interval time.Duration, now time.Time) time.Duration {
+
+	wait, _ := hostLimits.reserve(rawurl, interval, now)
+	return wait
+}
+
+func TestHostLimiterReserve(t *testing.T) {
+
+	SetHostLimits(map[string]time.Duration{"itunes.apple.com": 3 * time.Second})
+	defer SetHostLimits(nil)
+	assert.Equal(t, map[string]time.Duration{"itunes.apple.com": 3 * time.Second}, GetHostLimits())
+
+	now := time.Now()
+	assert.Equal(t, time.Duration(0), reserveWait("https://itunes.apple.com/lookup?id=1", 0, now))
+	assert.Equal(t, 3*time.Second, reserveWait("https://itunes.apple.com/lookup?id=2", 0, now))
+	assert.Equal(t, 5*time.Second, reserveWait("https://itunes.apple.com/lookup?id=3", 0, now.Add(time.Second)))
+
+	// the slot in the past is not waited
+	assert.Equal(t, time.Duration(0), reserveWait("https://itunes.apple.com/lookup?id=4", 0, now.Add(time.Minute)))
+
+	assert.Equal(t, time.Duration(0), reserveWait("https://podcasts.apple.com/ua/podcast/id1", 0, now))
+	assert.Equal(t, time.Duration(0), reserveWait("https://podcasts.apple.com/ua/podcast/id2", 0, now))
+	assert.Equal(t, time.Duration(0), reserveWait("://invalid", 0, now))
+
+	// the longer interval of the caller wins
+	assert.Equal(t, time.Duration(0), reserveWait("https://podcasts.apple.com/ua/podcast/id3", time.Minute, now))
+	assert.Equal(t, time.Minute, reserveWait("https://podcasts.apple.com/ua/podcast/id4", time.Second, now))
+}
+
+type testSharedLimiter struct {
+	hosts []string
+}
+
+func (l *testSharedLimiter) Reserve(host string, interval time.Duration, now time.Time) (time.Duration, error) {
+
+	l.hosts = append(l.hosts, host)
+	if len(l.hosts) > 1 {
+		return 0, &BudgetError{Host: host, Window: "hour", Limit: 1}
+	}
+	return interval, nil
+}
+
+func TestSharedLimiter(t *testing.T) {
+
+	limiter := &testSharedLimiter{}
+	SetSharedLimiter(limiter)
+	defer SetSharedLimiter(nil)
+
+	wait, err := hostLimits.reserve("https://itunes.apple.com/lookup?id=1", time.Second, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	err = waitHost("https://itunes.apple.com/lookup?id=2", 0)
+	assert.True(t, IsBudgetError(err))
+	assert.Equal(t, ErrorClassBudget, GetErrorClass(err))
+	assert.Equal(t, "Budget of itunes.apple.com is exhausted: 1 requests per hour", err.Error())
+	assert.Equal(t, []string{"itunes.apple.com", "itunes.apple.com"}, limiter.hosts)
+}
+
+func TestWaitHost(t *testing.T) {
+
+	SetHostLimits(map[string]time.Duration{"127.0.0.1": 50 * time.Millisecond})
+	defer SetHostLimits(nil)
+
+	start := time.Now()
+	waitHost("http://127.0.0.1:8080/1", 0)
+	waitHost("http://127.0.0.1:8080/2", 0)
+	waitHost("http://127.0.0.1:8080/3", 0)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+type PageScraperOptions struct {
+	LookupURL []string
+	Selectors map[string]string
+	Duration  time.Duration
+	// Attribute of elements kept in Attr, e.g. data-href
+	Attribute string
+}
+
+type PageElement struct {
+	Text string
+	Href string
+	Attr string
+}
+
+type PageResult struct {
+	URL    string
+	Fields map[string][]*PageElement
+	Error  error
+}
+
+func GetPageScraperOptions(url []string, selectors map[string]string, delay time.Duration) *PageScraperOptions {
+
+	return &PageScraperOptions{LookupURL: url, Selectors: selectors, Duration: delay}
+}
+
+func ScrapePages(opt *PageScraperOptions) chan *PageResult {
+
+	urls := len(opt.LookupURL)
+	out := make(chan *PageResult, urls)
+
+	go func() {
+
+		var limiter <-chan time.Time
+		if opt.Duration > 0 {
+			ticker := time.NewTicker(opt.Duration)
+			defer ticker.Stop()
+			limiter = ticker.C
+		}
+
+		for i, url := range opt.LookupURL {
+
+			if limiter != nil {
+				<-limiter
+			}
+
+			Emit(&Event{
+				Kind:    EventRequest,
+				Message: fmt.Sprintf("Scraping (%d/%d)", i+1, urls),
+				URL:     url,
+				Done:    i,
+				Total:   urls,
+				ETA:     getETA(urls-i-1, opt.Duration),
+			})
+			res := getPageFromHTML(url, opt.Selectors, opt.Attribute)
+			out <- res
+			if IsBudgetError(res.Error) {
+				Emit(&Event{Level: LevelWarn, Message: fmt.Sprintf("Scraping stopped (%d/%d)", i, urls), Err: res.Error})
+				break
+			}
+		}
+
+		close(out)
+	}()
+
+	return out
+}
+
+func getPageFromHTML(url string, selectors map[string]string, attr string) *PageResult {
+
+	res := &PageResult{URL: url, Fields: map[string][]*PageElement{}}
+
+	col := colly.NewCollector()
+	for field, pattern := range selectors {
+
+		func(field string) {
+			col.OnHTML(pattern, func(el *colly.HTMLElement) {
+				elem := &PageElement{Text: strings.TrimSpace(el.Text)}
+				if href := el.Attr("href"); href != "" {
+					elem.Href = el.Request.AbsoluteURL(href)
+				}
+				if attr != "" {
+					elem.Attr = el.Attr(attr)
+				}
+				res.Fields[field] = append(res.Fields[field], elem)
+			})
+		}(field)
+	}
+
+	col.OnError(func(resp *colly.Response, err error) {
+		res.Error = getScrapeError(url, resp, err)
+	})
+
+	if err := waitHost(url, 0); err != nil {
+		res.Error = err
+		return res
+	}
+	if err := visit(col, url, func() error { return res.Error }); err != nil && res.Error == nil {
+		res.Error = err
+	}
+
+	return res
+}
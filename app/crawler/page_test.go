@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPageTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte(`<!DOCTYPE html>
+<html>
+<body>
+<p class="desc"> Show description </p>
+<a class="site" href="/website">Website</a>
+<a class="episode" href="http://x.com/ep/1">Episode #1</a>
+<a class="episode" href="http://x.com/ep/2">Episode #2</a>
+</body>
+</html>
+		`))
+	})
+
+	mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(404)
+		w.Write([]byte("<p>error</p>"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestScrapePages(t *testing.T) {
+
+	ts := newPageTestServer()
+	defer ts.Close()
+
+	opt := GetPageScraperOptions(
+		[]string{ts.URL + "/page"},
+		map[string]string{
+			"description": ".desc",
+			"website":     ".site",
+			"episodes":    ".episode",
+			"missing":     ".missing",
+		},
+		0,
+	)
+	opt.Attribute = "class"
+
+	for page := range ScrapePages(opt) {
+		assert.Nil(t, page.Error)
+		assert.Equal(t, ts.URL+"/page", page.URL)
+
+		assert.Len(t, page.Fields["description"], 1)
+		assert.Equal(t, "Show description", page.Fields["description"][0].Text)
+		assert.Empty(t, page.Fields["description"][0].Href)
+
+		assert.Len(t, page.Fields["website"], 1)
+		assert.Equal(t, ts.URL+"/website", page.Fields["website"][0].Href)
+		assert.Equal(t, "site", page.Fields["website"][0].Attr)
+
+		assert.Len(t, page.Fields["episodes"], 2)
+		assert.Equal(t, "Episode #1", page.Fields["episodes"][0].Text)
+		assert.Equal(t, "http://x.com/ep/2", page.Fields["episodes"][1].Href)
+
+		assert.Empty(t, page.Fields["missing"])
+	}
+
+	opt = GetPageScraperOptions([]string{ts.URL + "/404"}, map[string]string{}, 0)
+	for page := range ScrapePages(opt) {
+		assert.NotNil(t, page.Error)
+		assert.Equal(t, ErrorClassNotFound, GetErrorClass(page.Error))
+	}
+}
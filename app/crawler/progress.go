@@ -0,0 +1,353 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// levels of progress events
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Levels is the list of levels from the most verbose one
+var Levels = []string{LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+// kinds of progress events
+const (
+	EventStageStart = "stage_start"
+	EventStageEnd   = "stage_end"
+	EventRequest    = "request"
+	EventItem       = "item"
+	EventWait       = "wait"
+	EventMessage    = "message"
+	EventResponse   = "response"
+	EventRetry      = "retry"
+	EventCache      = "cache"
+)
+
+// formats of the progress output
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Formats is the list of supported progress formats
+var Formats = []string{FormatText, FormatJSON}
+
+// Event is the progress of the stage, fields which do not apply to the kind are empty
+type Event struct {
+	Time    time.Time
+	Level   string
+	Kind    string
+	Stage   string
+	Message string
+	URL     string
+	ID      int
+	// Status is the status code of the response, zero when the response is not received
+	Status int
+	// Done and Total are numbers of requests or items of the stage
+	Done  int
+	Total int
+	// Rate is the request rate in requests per second
+	Rate float64
+	// Wait is the time waited for the rate limit, ETA is the estimated time left
+	Wait     time.Duration
+	ETA      time.Duration
+	Duration time.Duration
+	Loaded   int
+	Failed   int
+	// Cached is the number of items loaded by previous runs
+	Cached int
+	Err    error
+}
+
+// String returns the human-readable message of the event
+func (e *Event) String() string {
+
+	msg := e.Message
+	if e.URL != "" {
+		msg += " - " + e.URL
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// MarshalJSON encodes the event as the flat object, durations are in seconds
+func (e *Event) MarshalJSON() ([]byte, error) {
+
+	type jsonEvent struct {
+		Time     time.Time `json:"time"`
+		Level    string    `json:"level"`
+		Kind     string    `json:"event"`
+		Stage    string    `json:"stage,omitempty"`
+		Message  string    `json:"msg,omitempty"`
+		URL      string    `json:"url,omitempty"`
+		ID       int       `json:"id,omitempty"`
+		Status   int       `json:"status,omitempty"`
+		Done     int       `json:"done,omitempty"`
+		Total    int       `json:"total,omitempty"`
+		Rate     float64   `json:"rate,omitempty"`
+		Wait     float64   `json:"wait_sec,omitempty"`
+		ETA      float64   `json:"eta_sec,omitempty"`
+		Duration float64   `json:"duration_sec,omitempty"`
+		Loaded   int       `json:"loaded,omitempty"`
+		Failed   int       `json:"failed,omitempty"`
+		Cached   int       `json:"cached,omitempty"`
+		Error    string    `json:"error,omitempty"`
+		Class    string    `json:"class,omitempty"`
+	}
+
+	res := &jsonEvent{
+		Time:     e.Time,
+		Level:    e.Level,
+		Kind:     e.Kind,
+		Stage:    e.Stage,
+		Message:  e.Message,
+		URL:      e.URL,
+		ID:       e.ID,
+		Status:   e.Status,
+		Done:     e.Done,
+		Total:    e.Total,
+		Rate:     e.Rate,
+		Wait:     e.Wait.Seconds(),
+		ETA:      e.ETA.Seconds(),
+		Duration: e.Duration.Seconds(),
+		Loaded:   e.Loaded,
+		Failed:   e.Failed,
+		Cached:   e.Cached,
+	}
+	if e.Err != nil {
+		res.Error = e.Err.Error()
+		res.Class = GetErrorClass(e.Err)
+	}
+	return json.Marshal(res)
+}
+
+// Progress receives progress events of stages
+type Progress interface {
+	Emit(e *Event)
+}
+
+// writerProgress writes events of the level and above, either as text lines or JSON lines
+type writerProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level int
+	json  bool
+}
+
+// NewProgress creates the progress writing events of the level and above in the format
+func NewProgress(w io.Writer, format string, level string) (Progress, error) {
+
+	lvl := indexOf(Levels, level)
+	if lvl < 0 {
+		return nil, errors.Errorf("Invalid progress level: %s, expected one of: %s", level, strings.Join(Levels, ", "))
+	}
+	if indexOf(Formats, format) < 0 {
+		return nil, errors.Errorf("Invalid progress format: %s, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+	return &writerProgress{w: w, level: lvl, json: format == FormatJSON}, nil
+}
+
+func (p *writerProgress) Emit(e *Event) {
+
+	if indexOf(Levels, e.Level) < p.level {
+		return
+	}
+
+	line := []byte{}
+	if p.json {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		prefix := e.Time.Format("2006/01/02 15:04:05") + " " + fmt.Sprintf("%-5s", strings.ToUpper(e.Level))
+		if e.Stage != "" {
+			prefix += " [" + e.Stage + "]"
+		}
+		line = []byte(prefix + " " + e.String() + "\n")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.w.Write(line)
+}
+
+var progress = struct {
+	sync.Mutex
+	p     Progress
+	stage string
+}{p: &writerProgress{w: os.Stderr, level: indexOf(Levels, LevelInfo)}}
+
+// multiProgress sends events to every progress
+type multiProgress []Progress
+
+// MultiProgress creates the progress sending events to all the given ones, e.g. to the output and metrics
+func MultiProgress(list ...Progress) Progress {
+
+	return multiProgress(list)
+}
+
+func (m multiProgress) Emit(e *Event) {
+
+	for _, p := range m {
+		p.Emit(e)
+	}
+}
+
+// GetProgress returns the current receiver of progress events
+func GetProgress() Progress {
+
+	progress.Lock()
+	defer progress.Unlock()
+
+	return progress.p
+}
+
+// SetProgress replaces the receiver of progress events, events are written as text to stderr by default
+func SetProgress(p Progress) {
+
+	progress.Lock()
+	defer progress.Unlock()
+
+	progress.p = p
+}
+
+// Emit sends the event to the progress, the time, the level and the current stage are set when they are empty
+func Emit(e *Event) {
+
+	progress.Lock()
+	p := progress.p
+	if e.Stage == "" {
+		e.Stage = progress.stage
+	}
+	progress.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Level == "" {
+		e.Level = LevelInfo
+	}
+	if e.Kind == "" {
+		e.Kind = EventMessage
+	}
+	p.Emit(e)
+}
+
+// Debugf emits the message of the debug level
+func Debugf(format string, args ...interface{}) {
+
+	Emit(&Event{Level: LevelDebug, Message: fmt.Sprintf(format, args...)})
+}
+
+// Infof emits the message of the info level
+func Infof(format string, args ...interface{}) {
+
+	Emit(&Event{Level: LevelInfo, Message: fmt.Sprintf(format, args...)})
+}
+
+// Warnf emits the message of the warn level
+func Warnf(format string, args ...interface{}) {
+
+	Emit(&Event{Level: LevelWarn, Message: fmt.Sprintf(format, args...)})
+}
+
+// StageStart emits the start of the stage, following events belong to it
+func StageStart(stage string) {
+
+	progress.Lock()
+	progress.stage = stage
+	progress.Unlock()
+
+	Emit(&Event{Kind: EventStageStart, Message: "Starting stage " + stage})
+}
+
+// StageEnd emits the end of the stage with the number of loaded and failed items
+func StageEnd(stage string, loaded int, failed int, duration time.Duration) {
+
+	level := LevelInfo
+	if failed > 0 {
+		level = LevelWarn
+	}
+	Emit(&Event{
+		Level:    level,
+		Kind:     EventStageEnd,
+		Stage:    stage,
+		Message:  fmt.Sprintf("Stage %s finished in %s, loaded %d, failed %d", stage, duration.Round(time.Millisecond), loaded, failed),
+		Loaded:   loaded,
+		Failed:   failed,
+		Duration: duration,
+	})
+
+	progress.Lock()
+	progress.stage = ""
+	progress.Unlock()
+}
+
+// ItemDone emits the result of the item, failures are warnings
+func ItemDone(id int, url string, err error) {
+
+	if err != nil {
+		Emit(&Event{Level: LevelWarn, Kind: EventItem, Message: fmt.Sprintf("Item %d failed", id), ID: id, URL: url, Err: err})
+		return
+	}
+	Emit(&Event{Level: LevelDebug, Kind: EventItem, Message: fmt.Sprintf("Item %d loaded", id), ID: id, URL: url})
+}
+
+// Response emits the response of the request with its duration
+func Response(url string, status int, duration time.Duration, err error) {
+
+	Emit(&Event{
+		Level:    LevelDebug,
+		Kind:     EventResponse,
+		Message:  fmt.Sprintf("Response %d in %s", status, duration.Round(time.Millisecond)),
+		URL:      url,
+		Status:   status,
+		Duration: duration,
+		Err:      err,
+	})
+}
+
+// Retry emits the item which is requested again after the failure
+func Retry(id int) {
+
+	Emit(&Event{Level: LevelDebug, Kind: EventRetry, Message: fmt.Sprintf("Item %d is retried", id), ID: id})
+}
+
+// CacheHits emits the number of items loaded by previous runs, the message is followed by the number
+func CacheHits(cached int, msg string) {
+
+	Emit(&Event{Kind: EventCache, Message: fmt.Sprintf("%s %d", msg, cached), Cached: cached})
+}
+
+// getETA returns the time left for requests with the delay
+func getETA(left int, delay time.Duration) time.Duration {
+
+	if left <= 0 {
+		return 0
+	}
+	return time.Duration(left) * delay
+}
+
+func indexOf(list []string, value string) int {
+
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,91 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordProgress struct {
+	events []*Event
+}
+
+func (p *recordProgress) Emit(e *Event) {
+	p.events = append(p.events, e)
+}
+
+func TestNewProgress(t *testing.T) {
+
+	_, err := NewProgress(os.Stderr, "xml", LevelInfo)
+	assert.Equal(t, "Invalid progress format: xml, expected one of: text, json", err.Error())
+	_, err = NewProgress(os.Stderr, FormatText, "trace")
+	assert.Equal(t, "Invalid progress level: trace, expected one of: debug, info, warn, error", err.Error())
+}
+
+func TestTextProgress(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	p, _ := NewProgress(buf, FormatText, LevelInfo)
+	now := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+
+	p.Emit(&Event{Time: now, Level: LevelDebug, Message: "Item 1 loaded"})
+	p.Emit(&Event{Time: now, Level: LevelWarn, Stage: "details", Message: "Item 2 failed", URL: "https://itunes.apple.com", Err: &NotFoundError{URL: "https://itunes.apple.com"}})
+	assert.Equal(t, "2026/10/19 10:30:00 WARN  [details] Item 2 failed - https://itunes.apple.com: "+(&NotFoundError{URL: "https://itunes.apple.com"}).Error()+"\n", buf.String())
+}
+
+func TestJSONProgress(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	p, _ := NewProgress(buf, FormatJSON, LevelDebug)
+	now := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+
+	p.Emit(&Event{Time: now, Level: LevelInfo, Kind: EventRequest, Stage: "details", Message: "Requesting (2/10, 0.50 req/s)", Done: 1, Total: 10, Rate: 0.5, ETA: 16 * time.Second})
+	p.Emit(&Event{Time: now, Level: LevelWarn, Kind: EventItem, ID: 5, Err: &NotFoundError{URL: "https://itunes.apple.com"}})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	first := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(lines[0], &first))
+	assert.Equal(t, map[string]interface{}{
+		"time":    "2026-10-19T10:30:00Z",
+		"level":   "info",
+		"event":   "request",
+		"stage":   "details",
+		"msg":     "Requesting (2/10, 0.50 req/s)",
+		"done":    1.0,
+		"total":   10.0,
+		"rate":    0.5,
+		"eta_sec": 16.0,
+	}, first)
+
+	second := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(lines[1], &second))
+	assert.Equal(t, ErrorClassNotFound, second["class"])
+	assert.Equal(t, 5.0, second["id"])
+}
+
+func TestStageEvents(t *testing.T) {
+
+	rec := &recordProgress{}
+	SetProgress(rec)
+	defer SetProgress(&writerProgress{w: os.Stderr, level: indexOf(Levels, LevelInfo)})
+
+	StageStart("feed")
+	ItemDone(1, "https://example.com/rss", nil)
+	StageEnd("feed", 1, 2, time.Second)
+	Infof("Done")
+
+	assert.Len(t, rec.events, 4)
+	assert.Equal(t, EventStageStart, rec.events[0].Kind)
+	assert.Equal(t, "feed", rec.events[1].Stage)
+	assert.Equal(t, LevelDebug, rec.events[1].Level)
+	assert.Equal(t, LevelWarn, rec.events[2].Level)
+	assert.Equal(t, "Stage feed finished in 1s, loaded 1, failed 2", rec.events[2].Message)
+	assert.Equal(t, "", rec.events[3].Stage)
+	assert.Equal(t, EventMessage, rec.events[3].Kind)
+}
@@ -1,13 +1,11 @@
 package crawler
 
 import (
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"sync"
 	"time"
-
-	"github.com/pkg/errors"
 )
 
 type RequestResult struct {
@@ -23,10 +21,18 @@ type RequestOptions struct {
 type LimitedRequestOptions struct {
 	LookupURL []string
 	Duration  time.Duration
+	// Throttle adapts the delay between requests, the fixed Duration is used when it is not set
+	Throttle *Throttle
+	// IsEmpty checks the decoded entity, several empty results in a row slow requests down like the ban
+	IsEmpty func(entity interface{}) bool
+	Done    <-chan struct{}
 }
 
 type RequestDecoder func(url string, body []byte) (interface{}, error)
 
+// httpClient is the client of API requests, the timeout stops hung servers from blocking the stage
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
 func RequestEntities(opt *RequestOptions, decoder RequestDecoder) chan *RequestResult {
 
 	numb := len(opt.LookupURL)
@@ -39,13 +45,17 @@ func RequestEntities(opt *RequestOptions, decoder RequestDecoder) chan *RequestR
 
 		go func(url string) {
 
-			results <- getEntitiesFromRequest(url, decoder)
+			results <- getEntitiesFromRequest(url, decoder, 0)
 			wg.Done()
 		}(url)
 	}
-	wg.Wait()
 
-	close(results)
+	// results are streamed as soon as they arrive, channel is closed after the last one
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
 	return results
 }
 
@@ -61,17 +71,45 @@ func RequestEntitiesWithLimiter(opt *LimitedRequestOptions, decoder RequestDecod
 	}
 	close(in)
 
-	limiter := time.Tick(opt.Duration)
+	throttle := opt.Throttle
+	if throttle == nil {
+		throttle = NewThrottle(opt.Duration, opt.Duration, opt.Duration)
+	}
 
 	go func(in chan string, out chan *RequestResult) {
 
 		i := 1
 		for url := range in {
 
-			<-limiter
-
-			log.Printf("Requesting (%d/%d) - %s", i, urls, url)
-			out <- getEntitiesFromRequest(url, decoder)
+			select {
+			case <-time.After(throttle.Next()):
+			case <-opt.Done:
+				Infof("Requesting stopped (%d/%d)", i-1, urls)
+				close(out)
+				return
+			}
+
+			Emit(&Event{
+				Kind:    EventRequest,
+				Message: fmt.Sprintf("Requesting (%d/%d, %.2f req/s)", i, urls, throttle.Rate()),
+				URL:     url,
+				Done:    i - 1,
+				Total:   urls,
+				Rate:    throttle.Rate(),
+				ETA:     getETA(urls-i, throttle.Delay()),
+			})
+			// the throttle delay is booked in the shared limiter too, so processes of the machine share the rate
+			res := getEntitiesFromRequest(url, decoder, throttle.Delay())
+			if IsBudgetError(res.Error) {
+				Emit(&Event{Level: LevelWarn, Message: fmt.Sprintf("Requesting stopped (%d/%d)", i-1, urls), Err: res.Error})
+				out <- res
+				break
+			}
+			empty := res.Error == nil && opt.IsEmpty != nil && opt.IsEmpty(res.Entity)
+			if event := throttle.Observe(res, empty); event != nil {
+				Emit(&Event{Level: LevelWarn, Kind: EventWait, Message: event.String(), URL: event.URL, Wait: event.Wait()})
+			}
+			out <- res
 			i++
 		}
 
@@ -81,22 +119,39 @@ func RequestEntitiesWithLimiter(opt *LimitedRequestOptions, decoder RequestDecod
 	return out
 }
 
-func getEntitiesFromRequest(url string, decoder RequestDecoder) *RequestResult {
+func getEntitiesFromRequest(url string, decoder RequestDecoder, interval time.Duration) *RequestResult {
 
-	resp, err := http.Get(url)
-	if err != nil {
+	if err := waitHost(url, interval); err != nil {
 		return &RequestResult{url, nil, err}
 	}
-	if resp.StatusCode != 200 {
-		return &RequestResult{url, nil, errors.Errorf("Unreachable URL: %s", url)}
+
+	started := time.Now()
+	res, status := doRequest(url, decoder)
+	Response(url, status, time.Since(started), res.Error)
+	return res
+}
+
+// doRequest requests and decodes the URL, the status is zero when the response is not received
+func doRequest(url string, decoder RequestDecoder) (*RequestResult, int) {
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return &RequestResult{url, nil, newRequestError(url, err)}, 0
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return &RequestResult{url, nil, newStatusError(url, resp.StatusCode, resp.Header)}, resp.StatusCode
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return &RequestResult{url, nil, err}
+		return &RequestResult{url, nil, newRequestError(url, err)}, resp.StatusCode
 	}
 
 	res, err := decoder(url, body)
-	return &RequestResult{url, res, err}
+	if err != nil {
+		return &RequestResult{url, res, &DecodeError{URL: url, Err: err}}, resp.StatusCode
+	}
+	return &RequestResult{url, res, nil}, resp.StatusCode
 }
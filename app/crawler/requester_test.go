@@ -79,9 +79,11 @@ func TestRequestEntities(t *testing.T) {
 		assert.NotNil(t, en.Error)
 		assert.Equal(
 			t,
-			fmt.Sprintf("Unreachable URL: %s", en.URL),
-			errors.Cause(en.Error).Error(),
+			fmt.Sprintf("Unreachable URL: %s (status 404)", en.URL),
+			en.Error.Error(),
 		)
+		var notFound *NotFoundError
+		assert.True(t, errors.As(en.Error, &notFound))
 	}
 }
 
@@ -125,8 +127,56 @@ func TestRequestEntitiesWithLimiter(t *testing.T) {
 		assert.NotNil(t, en.Error)
 		assert.Equal(
 			t,
-			fmt.Sprintf("Unreachable URL: %s", en.URL),
-			errors.Cause(en.Error).Error(),
+			fmt.Sprintf("Unreachable URL: %s (status 404)", en.URL),
+			en.Error.Error(),
 		)
+		var notFound *NotFoundError
+		assert.True(t, errors.As(en.Error, &notFound))
 	}
 }
+
+func TestRequestEntitiesWithLimiterDone(t *testing.T) {
+
+	ts := newRequesterTestServer()
+	defer ts.Close()
+
+	done := make(chan struct{})
+	opt := &LimitedRequestOptions{
+		LookupURL: []string{ts.URL + "/test/1", ts.URL + "/test/2"},
+		Duration:  time.Second,
+		Done:      done,
+	}
+
+	results := RequestEntitiesWithLimiter(opt, func(url string, body []byte) (interface{}, error) {
+		return body, nil
+	})
+
+	first := <-results
+	assert.Nil(t, first.Error)
+	close(done)
+
+	_, ok := <-results
+	assert.False(t, ok)
+}
+
+func TestGetEntitiesFromRequestTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	timeout := httpClient.Timeout
+	httpClient.Timeout = 50 * time.Millisecond
+	defer func() { httpClient.Timeout = timeout }()
+
+	res := getEntitiesFromRequest(ts.URL+"/slow", func(url string, body []byte) (interface{}, error) {
+		return body, nil
+	}, 0)
+
+	var timeoutErr *TimeoutError
+	assert.True(t, errors.As(res.Error, &timeoutErr))
+	assert.Equal(t, ErrorClassTimeout, GetErrorClass(res.Error))
+}
@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// throttle events
+const (
+	ThrottleBan     = "ban"
+	ThrottleAnomaly = "anomaly"
+)
+
+// ThrottleRateStep is the rate increase in requests per second after the successful response
+var ThrottleRateStep = 0.01
+
+// ThrottleAnomalies is the number of empty results in a row which are treated as the ban
+var ThrottleAnomalies = 3
+
+// ThrottleEvent reports the back off of the throttle
+type ThrottleEvent struct {
+	// Kind is either ThrottleBan or ThrottleAnomaly
+	Kind  string
+	URL   string
+	Delay time.Duration
+	// Pause is the one-off wait before the next request, e.g. Retry-After of the response
+	Pause time.Duration
+	Err   error
+}
+
+// Wait returns the wait before the next request
+func (e *ThrottleEvent) Wait() time.Duration {
+
+	if e.Pause > e.Delay {
+		return e.Pause
+	}
+	return e.Delay
+}
+
+func (e *ThrottleEvent) String() string {
+
+	msg := "Rate limited at " + e.URL
+	if e.Kind == ThrottleAnomaly {
+		msg = "Empty results in a row at " + e.URL
+	}
+	msg += ", delay is increased to " + e.Delay.String()
+	if e.Pause > e.Delay {
+		msg += ", waiting " + e.Pause.String()
+	}
+	return msg
+}
+
+// Throttle adapts the delay between requests within bounds: the rate grows additively while responses succeed
+// and the delay is doubled on 403 and 429 responses or several empty results in a row
+type Throttle struct {
+	mu        sync.Mutex
+	delay     time.Duration
+	min       time.Duration
+	max       time.Duration
+	pause     time.Duration
+	anomalies int
+	bans      int
+}
+
+// NewThrottle creates the throttle starting with the delay, the throttle with equal bounds keeps the delay fixed
+func NewThrottle(delay time.Duration, min time.Duration, max time.Duration) *Throttle {
+
+	if max < min {
+		max = min
+	}
+	t := &Throttle{min: min, max: max}
+	t.delay = t.clamp(delay)
+	return t
+}
+
+// Delay returns the current delay between requests
+func (t *Throttle) Delay() time.Duration {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.delay
+}
+
+// Rate returns the current rate in requests per second, zero delay is reported as zero rate
+func (t *Throttle) Rate() float64 {
+
+	return getRate(t.Delay())
+}
+
+// Bans returns the number of back offs
+func (t *Throttle) Bans() int {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.bans
+}
+
+// Next returns the wait before the next request
+func (t *Throttle) Next() time.Duration {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wait := t.delay
+	if t.pause > wait {
+		wait = t.pause
+	}
+	t.pause = 0
+	return wait
+}
+
+// Observe adapts the delay to the result of the request, the event is returned when the throttle backs off
+func (t *Throttle) Observe(res *RequestResult, empty bool) *ThrottleEvent {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var limited *RateLimitedError
+	switch {
+	case res.Error != nil && errors.As(res.Error, &limited):
+		t.anomalies = 0
+		t.pause = limited.RetryAfter
+		return t.backOff(ThrottleBan, res)
+	case empty:
+		if t.anomalies++; t.anomalies < ThrottleAnomalies {
+			return nil
+		}
+		t.anomalies = 0
+		return t.backOff(ThrottleAnomaly, res)
+	case res.Error == nil:
+		t.anomalies = 0
+		t.speedUp()
+	}
+	return nil
+}
+
+func (t *Throttle) backOff(kind string, res *RequestResult) *ThrottleEvent {
+
+	t.bans++
+	delay := t.delay * 2
+	if delay == 0 {
+		delay = time.Second
+	}
+	t.delay = t.clamp(delay)
+	return &ThrottleEvent{Kind: kind, URL: res.URL, Delay: t.delay, Pause: t.pause, Err: res.Error}
+}
+
+func (t *Throttle) speedUp() {
+
+	if t.delay <= t.min {
+		return
+	}
+	rate := getRate(t.delay) + ThrottleRateStep
+	t.delay = t.clamp(time.Duration(float64(time.Second) / rate))
+}
+
+func (t *Throttle) clamp(delay time.Duration) time.Duration {
+
+	switch {
+	case delay < t.min:
+		return t.min
+	case delay > t.max:
+		return t.max
+	}
+	return delay
+}
+
+func getRate(delay time.Duration) float64 {
+
+	if delay <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(delay)
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+
+	th := NewThrottle(2*time.Second, time.Second, 8*time.Second)
+	assert.Equal(t, 0.5, th.Rate())
+
+	// the rate grows additively while responses succeed
+	ok := &RequestResult{URL: "https://itunes.apple.com/lookup?id=1"}
+	assert.Nil(t, th.Observe(ok, false))
+	assert.InDelta(t, 0.51, th.Rate(), 0.0001)
+	for i := 0; i < 100; i++ {
+		th.Observe(ok, false)
+	}
+	assert.Equal(t, time.Second, th.Delay())
+
+	// the delay is doubled on the ban, Retry-After is waited once
+	banned := &RequestResult{URL: ok.URL, Error: &RateLimitedError{URL: ok.URL, RetryAfter: time.Minute}}
+	event := th.Observe(banned, false)
+	assert.Equal(t, ThrottleBan, event.Kind)
+	assert.Equal(t, 2*time.Second, event.Delay)
+	assert.Equal(t, "Rate limited at "+ok.URL+", delay is increased to 2s, waiting 1m0s", event.String())
+	assert.Equal(t, time.Minute, th.Next())
+	assert.Equal(t, 2*time.Second, th.Next())
+
+	th.Observe(banned, false)
+	th.Observe(banned, false)
+	th.Observe(banned, false)
+	assert.Equal(t, 8*time.Second, th.Delay())
+	assert.Equal(t, 4, th.Bans())
+
+	// several empty results in a row are treated as the ban
+	th = NewThrottle(time.Second, time.Second, time.Minute)
+	assert.Nil(t, th.Observe(ok, true))
+	assert.Nil(t, th.Observe(ok, true))
+	event = th.Observe(ok, true)
+	assert.Equal(t, ThrottleAnomaly, event.Kind)
+	assert.Equal(t, 2*time.Second, th.Delay())
+	th.Observe(ok, true)
+	th.Observe(ok, false)
+	assert.Nil(t, th.Observe(ok, true))
+
+	// equal bounds keep the delay fixed
+	th = NewThrottle(0, 0, 0)
+	th.Observe(banned, false)
+	assert.Equal(t, time.Duration(0), th.Delay())
+	assert.Equal(t, float64(0), th.Rate())
+}
+
+func TestRequestEntitiesWithThrottle(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/banned" {
+			w.WriteHeader(429)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	th := NewThrottle(10*time.Millisecond, 10*time.Millisecond, 40*time.Millisecond)
+	opt := &LimitedRequestOptions{
+		LookupURL: []string{ts.URL + "/banned", ts.URL + "/empty", ts.URL + "/empty", ts.URL + "/empty"},
+		Throttle:  th,
+		IsEmpty:   func(entity interface{}) bool { return true },
+	}
+	results := RequestEntitiesWithLimiter(opt, func(url string, body []byte) (interface{}, error) {
+		return body, nil
+	})
+	for range results {
+	}
+	assert.Equal(t, 2, th.Bans())
+	assert.Equal(t, 40*time.Millisecond, th.Delay())
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AppleURL represents parsed podcasts.apple.com or itunes.apple.com URL
+type AppleURL struct {
+	Storefront string
+	Kind       string
+	Slug       string
+	ID         int
+	EpisodeID  int
+}
+
+var urlKinds = map[string]bool{
+	"podcast": true,
+	"genre":   true,
+	"channel": true,
+	"artist":  true,
+}
+
+// ParseURL parses show, episode, genre and lookup URLs, e.g.
+// https://podcasts.apple.com/us/podcast/slug/id123?i=456 or https://itunes.apple.com/podcast/id123?uo=4
+func ParseURL(raw string) (*AppleURL, error) {
+
+	src := strings.TrimSpace(raw)
+	if !strings.Contains(src, "://") {
+		src = "https://" + src
+	}
+
+	parsed, err := url.Parse(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid URL: %s", raw)
+	}
+
+	segments := []string{}
+	for _, seg := range strings.Split(parsed.Path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+
+	res := &AppleURL{}
+	if len(segments) > 0 && len(segments[0]) == 2 {
+		res.Storefront = strings.ToLower(segments[0])
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && urlKinds[segments[0]] {
+		res.Kind = segments[0]
+		segments = segments[1:]
+	}
+
+	query := parsed.Query()
+	if last := len(segments) - 1; last >= 0 && isIDSegment(segments[last]) {
+		res.ID, err = strconv.Atoi(strings.TrimPrefix(segments[last], "id"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ID cannot be parsed from URL: %s", raw)
+		}
+		segments = segments[:last]
+	} else if id := query.Get("id"); id != "" {
+		res.ID, err = strconv.Atoi(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ID cannot be parsed from URL: %s", raw)
+		}
+	} else {
+		return nil, errors.Errorf("ID cannot be parsed from URL: %s", raw)
+	}
+
+	if len(segments) > 0 {
+		res.Slug = segments[len(segments)-1]
+	}
+
+	if episode := query.Get("i"); episode != "" {
+		res.EpisodeID, err = strconv.Atoi(episode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Episode ID cannot be parsed from URL: %s", raw)
+		}
+	}
+
+	return res, nil
+}
+
+// idSegment matches the last path segment with the ID, e.g. id123 or 123, slugs like idea-cast are not IDs
+var idSegment = regexp.MustCompile(`^(id)?\d+$`)
+
+func isIDSegment(seg string) bool {
+
+	return idSegment.MatchString(seg)
+}
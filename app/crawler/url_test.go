@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+
+	tests := map[string]*AppleURL{
+		"https://podcasts.apple.com/us/podcast/the-daily/id1200361736": &AppleURL{
+			Storefront: "us", Kind: "podcast", Slug: "the-daily", ID: 1200361736,
+		},
+		"https://podcasts.apple.com/us/podcast/the-daily/id1200361736?i=1000456": &AppleURL{
+			Storefront: "us", Kind: "podcast", Slug: "the-daily", ID: 1200361736, EpisodeID: 1000456,
+		},
+		"https://podcasts.apple.com/gb/podcast/the-daily/id1200361736/": &AppleURL{
+			Storefront: "gb", Kind: "podcast", Slug: "the-daily", ID: 1200361736,
+		},
+		"https://itunes.apple.com/us/podcast/the-daily/id1200361736?mt=2&uo=4": &AppleURL{
+			Storefront: "us", Kind: "podcast", Slug: "the-daily", ID: 1200361736,
+		},
+		"https://itunes.apple.com/podcast/id1200361736": &AppleURL{
+			Kind: "podcast", ID: 1200361736,
+		},
+		"podcasts.apple.com/ua/genre/podcasts-arts/id1301": &AppleURL{
+			Storefront: "ua", Kind: "genre", Slug: "podcasts-arts", ID: 1301,
+		},
+		"https://podcasts.apple.com/us/podcast/idea-cast/id152022135": &AppleURL{
+			Storefront: "us", Kind: "podcast", Slug: "idea-cast", ID: 152022135,
+		},
+		"https://itunes.apple.com/lookup?id=1200361736": &AppleURL{
+			Slug: "lookup", ID: 1200361736,
+		},
+	}
+
+	for url, expected := range tests {
+		res, err := ParseURL(url)
+		assert.Nil(t, err, url)
+		assert.Equal(t, expected, res, url)
+	}
+
+	_, err := ParseURL("https://podcasts.apple.com/us/podcast/the-daily")
+	assert.Equal(t, "ID cannot be parsed from URL: https://podcasts.apple.com/us/podcast/the-daily", err.Error())
+
+	_, err = ParseURL("https://podcasts.apple.com/us/podcast/the-daily/idx")
+	assert.Equal(t, "ID cannot be parsed from URL: https://podcasts.apple.com/us/podcast/the-daily/idx", err.Error())
+
+	_, err = ParseURL("https://podcasts.apple.com/us/podcast/idea-cast")
+	assert.Equal(t, "ID cannot be parsed from URL: https://podcasts.apple.com/us/podcast/idea-cast", err.Error())
+
+	_, err = ParseURL("https://podcasts.apple.com/us/podcast/the-daily/id99999999999999999999")
+	assert.Equal(t, "strconv.Atoi: parsing \"99999999999999999999\": value out of range", errors.Cause(err).Error())
+
+	_, err = ParseURL("https://podcasts.apple.com/us/podcast/the-daily/id1?i=x")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/lock"
+	"github.com/zhikiri/itunes.podcasts/app/schedule"
+
+	"github.com/pkg/errors"
+)
+
+// daemonJob is the part of the pipeline run by the daemon on its own schedule
+type daemonJob struct {
+	name     string
+	from     string
+	until    string
+	interval time.Duration
+}
+
+// daemonJobs are jobs of the daemon with default intervals, feeds are compacted right after they are loaded
+var daemonJobs = []*daemonJob{
+	{name: stageGenres, from: stageGenres, until: stageGenres, interval: 7 * 24 * time.Hour},
+	{name: stageShows, from: stageShows, until: stageShows, interval: 24 * time.Hour},
+	{name: stageDetails, from: stageDetails, until: stageDetails, interval: 10 * time.Minute},
+	{name: stageFeed, from: stageFeed, until: stageCompact, interval: time.Hour},
+}
+
+// daemonFlags are flags of the daemon which are not passed to runs
+var daemonFlags = map[string]bool{"status": true}
+
+// getDaemonJobs returns jobs with intervals of the config schedule
+func getDaemonJobs(cfg *config.Config) ([]*schedule.Job, error) {
+
+	names := make([]string, 0, len(daemonJobs))
+	jobs := make([]*schedule.Job, 0, len(daemonJobs))
+	for _, job := range daemonJobs {
+		interval := job.interval
+		if value, ok := cfg.Schedule[job.name]; ok {
+			// intervals are validated on load
+			interval, _ = time.ParseDuration(value)
+		}
+		names = append(names, job.name)
+		jobs = append(jobs, &schedule.Job{Name: job.name, Interval: interval})
+	}
+
+	for name := range cfg.Schedule {
+		if !contains(names, name) {
+			return nil, usageErrorf("Config schedule has unknown job %s, expected one of: %s", name, strings.Join(names, ", "))
+		}
+	}
+	return jobs, nil
+}
+
+// getDaemonRunArgs returns arguments of the run of the job, flags set for the daemon are passed to the run
+func getDaemonRunArgs(fs *flag.FlagSet, job *schedule.Job) []string {
+
+	args := []string{"run"}
+	names := []string{}
+	fs.Visit(func(fl *flag.Flag) {
+		if !daemonFlags[fl.Name] {
+			names = append(names, fl.Name)
+		}
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-"+name+"="+fs.Lookup(name).Value.String())
+	}
+
+	for _, dj := range daemonJobs {
+		if dj.name == job.Name {
+			// the schedule decides when stages run, so outputs are never skipped as fresh
+			args = append(args, "-from", dj.from, "-until", dj.until, "-fresh", "0")
+		}
+	}
+	return args
+}
+
+// newDaemonRunner runs jobs as runs of this executable, the run is interrupted when done is closed
+func newDaemonRunner(fs *flag.FlagSet, done <-chan struct{}) schedule.Runner {
+
+	return func(job *schedule.Job) (int, error) {
+		self, err := os.Executable()
+		if err != nil {
+			return exitFailure, errors.Wrap(err, "Cannot find itupod executable")
+		}
+
+		cmd := exec.Command(self, getDaemonRunArgs(fs, job)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err = cmd.Start(); err != nil {
+			return exitFailure, errors.Wrap(err, "Cannot start run")
+		}
+
+		crawler.Infof("Job %s started, run pid %d", job.Name, cmd.Process.Pid)
+		finished := make(chan struct{})
+		go func() {
+			select {
+			case <-done:
+				// the run stops after the current stage
+				cmd.Process.Signal(syscall.SIGTERM)
+			case <-finished:
+			}
+		}()
+		err = cmd.Wait()
+		close(finished)
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		if err != nil {
+			return exitFailure, errors.Wrap(err, "Run failed")
+		}
+		return exitOK, nil
+	}
+}
+
+// actionDaemon runs jobs on schedule until the process is interrupted, the status of jobs is served at /status of the address
+func actionDaemon(fs *flag.FlagSet, cfg *config.Config, dir string, addr string) error {
+
+	jobs, err := getDaemonJobs(cfg)
+	if err != nil {
+		return err
+	}
+
+	stopOnError(errors.Wrap(os.MkdirAll(dir, 0755), "Cannot create output folder"))
+	path := filepath.Join(dir, schedule.FileName)
+	// the single daemon works with the output folder, runs lock the folder on their own
+	lk, err := lock.AcquireFile(path, 0)
+	stopOnError(err)
+	defer lk.Release()
+
+	s, err := schedule.New(path, jobs)
+	stopOnError(err)
+	stopOnError(s.Save())
+
+	if addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		stopOnError(errors.Wrap(err, "Cannot listen for status"))
+		mux := http.NewServeMux()
+		mux.Handle("/status", s)
+		go http.Serve(ln, mux)
+		crawler.Infof("Status is served at http://%s/status", ln.Addr())
+	}
+
+	done, stop := notifyStop("Stopping daemon after the current job")
+	defer stop()
+
+	for _, st := range s.Status().Jobs {
+		crawler.Infof("Job %s runs every %s, next run at %s", st.Name, st.Every, st.Next.Format(time.RFC3339))
+	}
+	run := newDaemonRunner(fs, done)
+	stopOnError(s.Loop(func(job *schedule.Job) (int, error) {
+		code, err := run(job)
+		crawler.Infof("Job %s finished with code %d", job.Name, code)
+		return code, err
+	}, done))
+	return nil
+}
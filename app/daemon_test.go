@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/schedule"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDaemonJobs(t *testing.T) {
+	jobs, err := getDaemonJobs(&config.Config{Schedule: map[string]string{"feed": "30m"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []*schedule.Job{
+		{Name: stageGenres, Interval: 7 * 24 * time.Hour},
+		{Name: stageShows, Interval: 24 * time.Hour},
+		{Name: stageDetails, Interval: 10 * time.Minute},
+		{Name: stageFeed, Interval: 30 * time.Minute},
+	}, jobs)
+
+	_, err = getDaemonJobs(&config.Config{Schedule: map[string]string{"pages": "1h"}})
+	assert.IsType(t, &usageError{}, err)
+	assert.Equal(t, "Config schedule has unknown job pages, expected one of: genres, shows, details, feed", err.Error())
+}
+
+func TestGetDaemonRunArgs(t *testing.T) {
+	cmd, _ := getCommand("daemon")
+	fs := newFlagSet(cmd)
+	cmd.init(fs)
+	assert.Nil(t, fs.Parse([]string{"-out", "/data", "-status", ":9000", "-wait", "1h"}))
+
+	assert.Equal(t,
+		[]string{"run", "-out=/data", "-wait=1h0m0s", "-from", "feed", "-until", "compact", "-fresh", "0"},
+		getDaemonRunArgs(fs, &schedule.Job{Name: stageFeed}),
+	)
+	assert.Equal(t,
+		[]string{"run", "-out=/data", "-wait=1h0m0s", "-from", "details", "-until", "details", "-fresh", "0"},
+		getDaemonRunArgs(fs, &schedule.Job{Name: stageDetails}),
+	)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/manifest"
+	"github.com/zhikiri/itunes.podcasts/app/plan"
+	"github.com/zhikiri/itunes.podcasts/app/queue"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+)
+
+func addDryRunFlag(fs *flag.FlagSet) *bool {
+
+	return fs.Bool("dry-run", false, "print the plan of requests with cache hits and ETA without sending them")
+}
+
+// actionDryRun prints plans of stages, files of the output folder are read only and nothing is requested.
+// In the pipeline mode stages read inputs of the output folder, fresh stages are skipped and details are loaded until the queue is done.
+// Plans are the report of the command, so they are printed to stdout as is and never go through the progress output.
+func actionDryRun(popt *pipelineOptions, opt *static.StoreOptions, inputs []string, pipeline bool) {
+	fmt.Println("Dry run, no requests are sent")
+	var plans []*plan.Plan
+	if pipeline {
+		plans = planPipeline(popt, opt)
+	} else {
+		plans = planStage(popt.stages[0], popt, inputs, opt, false)
+	}
+
+	limits := crawler.GetHostLimits()
+	requests := 0
+	var eta time.Duration
+	for _, p := range plans {
+		p.Print(os.Stdout, limits)
+		requests += len(p.URLs)
+		eta += p.ETA(limits)
+	}
+	if len(plans) > 1 {
+		fmt.Printf("Total requests %d, ETA %s\n", requests, eta)
+	}
+}
+
+// planPipeline plans stages of the run, stages after the one with requests are planned by the current files
+func planPipeline(popt *pipelineOptions, opt *static.StoreOptions) []*plan.Plan {
+	m, err := manifest.Open(filepath.Join(opt.Dir, manifest.FileName))
+	stopOnError(err)
+
+	res := []*plan.Plan{}
+	changed := false
+	for _, stage := range popt.stages {
+		if !changed && isStageFresh(m, opt, stage, popt.fresh, time.Now().UTC()) {
+			p := plan.New(stage)
+			p.Note("Output is fresh, the stage is skipped")
+			res = append(res, p)
+			continue
+		}
+		for _, p := range planStage(stage, popt, getStageInputs(opt, stage), opt, true) {
+			if changed {
+				p.Note("Input is changed by the previous stage, the plan is based on the current files")
+			}
+			changed = changed || len(p.URLs) > 0
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// planStage returns plans of the stage, the retry stage has the plan per stage of failed items
+func planStage(stage string, popt *pipelineOptions, inputs []string, opt *static.StoreOptions, loop bool) []*plan.Plan {
+	p := plan.New(stage)
+	if len(inputs) > 0 && stage != stageCompact {
+		if _, err := os.Stat(inputs[0]); err != nil {
+			p.Note("Input %s is not loaded yet", inputs[0])
+			return []*plan.Plan{p}
+		}
+	}
+
+	switch stage {
+	case stageGenres:
+		p.Concurrent = true
+		p.URLs = genre.GetRequestOptions(popt.rules.Get("genre"), opt.Country).LookupURL
+		p.Items = len(p.URLs)
+		if p.Cached = len(listCached(opt, genre.Kind)); p.Cached > 0 {
+			p.Note("Genres found %d, they are replaced", p.Cached)
+			p.Cached = 0
+		}
+	case stageShows:
+		planShows(p, popt, inputs[0], opt)
+	case stageDetails:
+		planDetails(p, popt, inputs[0], opt, loop)
+	case stagePages:
+		planPages(p, popt, inputs[0], opt)
+	case stageFeed:
+		planFeed(p, popt, inputs[0], opt)
+	case stageCompact:
+		src := *opt
+		if len(inputs) > 0 {
+			src.Dir = inputs[0]
+		}
+		p.Items = len(listCached(&src, show.Kind))
+		p.Note("Compact stage sends no requests")
+	case stageRetry:
+		return planRetry(popt, opt)
+	}
+	return []*plan.Plan{p}
+}
+
+func planShows(p *plan.Plan, popt *pipelineOptions, genrePath string, opt *static.StoreOptions) {
+	genres, err := genre.GetGenresFromFile(genrePath)
+	stopOnError(err)
+	p.Items = len(genres)
+	p.Concurrent = true
+
+	flt, err := popt.filter.getShowFilter(genres)
+	stopOnError(err)
+	if flt != nil {
+		genres = flt.Genres.Filter(genres)
+	}
+	p.Filtered = p.Items - len(genres)
+	p.URLs = show.GetShowsRequestOptions(genres, popt.rules.Get("show")).LookupURL
+	if cached := len(listCached(opt, show.Kind)); cached > 0 {
+		p.Note("Shows found %d, they are replaced", cached)
+	}
+}
+
+func planDetails(p *plan.Plan, popt *pipelineOptions, showPath string, opt *static.StoreOptions, loop bool) {
+	shows, err := show.GetShowsFromFile(showPath)
+	stopOnError(err)
+	p.Items = len(shows)
+	p.Delay = time.Duration(popt.delay) * time.Second
+
+	flt := planFilter(popt, opt)
+	allowed := flt.Shows(shows)
+	p.Filtered = len(shows) - len(allowed)
+
+	cached := map[int]bool{}
+	for _, id := range listCached(opt, show.DetailsKind) {
+		cached[id] = true
+	}
+	q := openPlanQueue(opt)
+
+	pending := []*show.Show{}
+	for _, sh := range allowed {
+		if cached[sh.ID] {
+			p.Cached++
+			continue
+		}
+		if q != nil {
+			if item, ok := q.Get(sh.ID); ok && (item.State == queue.Done || (item.State == queue.Failed && item.Attempts >= detailsAttempts)) {
+				p.Cached++
+				continue
+			}
+		}
+		pending = append(pending, sh)
+	}
+	if q != nil {
+		q.Close()
+	}
+
+	if !loop && len(pending) > popt.chunk {
+		p.Note("Shows left for next runs %d, chunk is %d", len(pending)-popt.chunk, popt.chunk)
+		pending = pending[:popt.chunk]
+	}
+	p.URLs = show.GetDetailsRequestOptions(pending, p.Delay).LookupURL
+}
+
+func planPages(p *plan.Plan, popt *pipelineOptions, showPath string, opt *static.StoreOptions) {
+	shows, err := show.GetShowsFromFile(showPath)
+	stopOnError(err)
+	p.Items = len(shows)
+	p.Delay = time.Duration(popt.delay) * time.Second
+
+	cached := map[int]bool{}
+	for _, id := range listCached(opt, show.PagesKind) {
+		cached[id] = true
+	}
+	pending := []*show.Show{}
+	for _, sh := range shows {
+		if cached[sh.ID] {
+			p.Cached++
+		} else {
+			pending = append(pending, sh)
+		}
+	}
+	if len(pending) > popt.chunk {
+		p.Note("Shows left for next runs %d, chunk is %d", len(pending)-popt.chunk, popt.chunk)
+		pending = pending[:popt.chunk]
+	}
+	p.URLs = show.GetPagesRequestOptions(pending, p.Delay).LookupURL
+}
+
+func planFeed(p *plan.Plan, popt *pipelineOptions, detailPath string, opt *static.StoreOptions) {
+	details, err := show.GetShowDetailsFromFile(detailPath)
+	stopOnError(err)
+	p.Items = len(details)
+	p.Concurrent = true
+
+	allowed := planFilter(popt, opt).Details(details)
+	p.Filtered = len(details) - len(allowed)
+
+	cached := map[int]*show.Feed{}
+	if store := openCached(opt, show.FeedKind); store != nil {
+		feeds, _ := show.LoadFeeds(store)
+		store.Close()
+		for _, feed := range feeds {
+			cached[feed.ID] = feed
+		}
+	}
+	stale := show.GetStaleShows(allowed, cached, popt.ttl, time.Now().UTC())
+	p.Cached = len(allowed) - len(stale)
+
+	for _, det := range stale {
+		if det.RSS != "" {
+			p.URLs = append(p.URLs, det.RSS)
+		}
+	}
+	if missing := len(stale) - len(p.URLs); missing > 0 {
+		p.Note("Shows without the feed URL %d", missing)
+	}
+}
+
+func planRetry(popt *pipelineOptions, opt *static.StoreOptions) []*plan.Plan {
+	fails, err := failures.Open(filepath.Join(opt.Dir, failures.FileName))
+	stopOnError(err)
+	flt := planFilter(popt, opt)
+	delay := time.Duration(popt.delay) * time.Second
+
+	res := []*plan.Plan{}
+	for _, stage := range []string{stageGenres, stageShows, stageDetails, stagePages, stageFeed} {
+		list := fails.List(stage)
+		if len(list) == 0 {
+			continue
+		}
+		p := plan.New(stageRetry + " " + stage)
+		p.Items = len(list)
+		p.Delay = delay
+		p.Concurrent = stage == stageShows || stage == stageFeed
+		for _, item := range list {
+			// IDs of genres and shows stages are genre IDs, the filter of shows does not apply
+			if stage != stageGenres && stage != stageShows && !flt.AllowsID(item.ID) {
+				p.Filtered++
+				continue
+			}
+			url := item.URL
+			if stage == stageDetails {
+				url = show.GetDetailsRequestOptions([]*show.Show{{ID: item.ID}}, delay).LookupURL[0]
+			}
+			p.URLs = append(p.URLs, url)
+		}
+		res = append(res, p)
+	}
+	if len(res) == 0 {
+		p := plan.New(stageRetry)
+		p.Note("Failures file has no items")
+		res = append(res, p)
+	}
+	return res
+}
+
+// planFilter resolves the filter with genres of the output folder
+func planFilter(popt *pipelineOptions, opt *static.StoreOptions) *show.Filter {
+	genres := []*genre.Genre{}
+	if store := openCached(opt, genre.Kind); store != nil {
+		genres, _ = genre.Load(store)
+		store.Close()
+	}
+	flt, err := popt.filter.getShowFilter(genres)
+	stopOnError(err)
+	return flt
+}
+
+// openPlanQueue opens the existing details queue, nil is returned when the queue is not created yet
+func openPlanQueue(opt *static.StoreOptions) *queue.Queue {
+	path := filepath.Join(opt.Dir, show.DetailsKind+".queue.log")
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	q, err := queue.Open(path)
+	stopOnError(err)
+	return q
+}
+
+// openCached opens the file of the kind in the output folder, nil is returned when the file does not exist
+func openCached(opt *static.StoreOptions, kind string) static.Store {
+	name, err := opt.FileName(kind)
+	stopOnError(err)
+	path := filepath.Join(opt.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	store, err := static.OpenFile(path)
+	stopOnError(err)
+	return store
+}
+
+// listCached returns IDs of the kind in the output folder
+func listCached(opt *static.StoreOptions, kind string) []int {
+	store := openCached(opt, kind)
+	if store == nil {
+		return []int{}
+	}
+	defer store.Close()
+
+	ids, err := store.List(kind)
+	if err != nil {
+		return []int{}
+	}
+	return ids
+}
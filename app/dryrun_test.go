@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanStage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dryrun.test")
+	defer os.RemoveAll(dir)
+
+	opt := &static.StoreOptions{Format: "jsonl", Dir: dir, Country: "us"}
+	out, _ := static.OpenStore(opt)
+	genre.Save(out, []*genre.Genre{
+		{ID: 1301, URL: "https://podcasts.apple.com/us/genre/podcasts-arts/id1301", Name: "Arts"},
+		{ID: 1305, URL: "https://podcasts.apple.com/us/genre/podcasts-kids-family/id1305", Name: "Kids & Family"},
+	})
+	show.Save(out, []*show.Show{{ID: 10}, {ID: 20}, {ID: 30}, {ID: 40}})
+	show.SaveDetails(out, []*show.ShowDetails{
+		{ID: 10, RSS: "https://feeds.example.com/10", Genres: []string{"1301"}},
+		{ID: 20, RSS: "https://feeds.example.com/20", Genres: []string{"1305"}},
+	})
+	show.SaveFeed(out, []*show.Feed{{ID: 10, FetchedAt: time.Now().UTC()}})
+	out.Close()
+
+	cfg := config.New()
+	cfg.Genres.Exclude = []string{"Kids & Family"}
+	cfg.Shows.Exclude = []int{40}
+	popt := &pipelineOptions{
+		rules:  rules.Default(),
+		delay:  2,
+		chunk:  1,
+		ttl:    time.Hour,
+		filter: newFilterOptions(cfg),
+	}
+
+	shows := planStage(stageShows, popt, getStageInputs(opt, stageShows), opt, false)[0]
+	assert.Equal(t, 2, shows.Items)
+	assert.Equal(t, 1, shows.Filtered)
+	assert.Equal(t, []string{"https://podcasts.apple.com/us/genre/podcasts-arts/id1301"}, shows.URLs)
+
+	// the chunk of shows without details is planned, the blocked one is left out
+	details := planStage(stageDetails, popt, getStageInputs(opt, stageDetails), opt, false)[0]
+	assert.Equal(t, 4, details.Items)
+	assert.Equal(t, 2, details.Cached)
+	assert.Equal(t, 1, details.Filtered)
+	assert.Equal(t, []string{"https://itunes.apple.com/lookup?id=30"}, details.URLs)
+	assert.Equal(t, 2*time.Second, details.ETA(nil))
+
+	feed := planStage(stageFeed, popt, getStageInputs(opt, stageFeed), opt, false)[0]
+	assert.Equal(t, 1, feed.Filtered)
+	assert.Equal(t, 1, feed.Cached)
+	assert.Len(t, feed.URLs, 0)
+
+	// the missing input is reported instead of the plan
+	missing := planStage(stageShows, popt, []string{filepath.Join(dir, "missing.jsonl")}, opt, false)[0]
+	assert.Len(t, missing.URLs, 0)
+	assert.Len(t, missing.Notes, 1)
+
+	// nothing is written to the output folder
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Len(t, entries, 4)
+}
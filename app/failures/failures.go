@@ -0,0 +1,138 @@
+package failures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+const FileName = "failures.jsonl"
+
+// Failure represents the failed item of the stage, attempts are counted across runs
+type Failure struct {
+	Stage    string    `json:"stage"`
+	ID       int       `json:"id"`
+	URL      string    `json:"url"`
+	Class    string    `json:"class"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// File is the dead-letter file which keeps the latest failure of every stage item
+type File struct {
+	path  string
+	order []string
+	items map[string]*Failure
+}
+
+// Open reads failures from the JSON Lines file, missing file means no failures
+func Open(path string) (*File, error) {
+
+	f := &File{path: path, order: []string{}, items: map[string]*Failure{}}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return f, nil
+	}
+
+	err := static.Load(path, func(body []byte) error {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		for {
+			item := &Failure{}
+			if err := dec.Decode(item); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			f.put(item)
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot read failures")
+	}
+	return f, nil
+}
+
+// Add records the failure of the stage item and increases its attempts
+func (f *File) Add(stage string, id int, url string, class string, cause error) *Failure {
+
+	item := &Failure{Stage: stage, ID: id, URL: url, Class: class, Error: cause.Error(), Attempts: 1}
+	if prev, ok := f.items[getKey(stage, id)]; ok {
+		item.Attempts = prev.Attempts + 1
+		if item.URL == "" {
+			item.URL = prev.URL
+		}
+	}
+	item.Time = time.Now().UTC()
+	f.put(item)
+	return item
+}
+
+// Resolve removes the stage item, e.g. when it was loaded successfully
+func (f *File) Resolve(stage string, id int) {
+
+	key := getKey(stage, id)
+	if _, ok := f.items[key]; !ok {
+		return
+	}
+	delete(f.items, key)
+	for i, k := range f.order {
+		if k == key {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// List returns failures of the stage in the order of adding
+func (f *File) List(stage string) []*Failure {
+
+	res := []*Failure{}
+	for _, key := range f.order {
+		if item := f.items[key]; item.Stage == stage {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// Len returns the number of failures of all stages
+func (f *File) Len() int {
+
+	return len(f.order)
+}
+
+// Save rewrites the file with the current failures
+func (f *File) Save() error {
+
+	return static.Save(f.path, func() ([]byte, error) {
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		for _, key := range f.order {
+			if err := enc.Encode(f.items[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+func (f *File) put(item *Failure) {
+
+	key := getKey(item.Stage, item.ID)
+	if _, ok := f.items[key]; !ok {
+		f.order = append(f.order, key)
+	}
+	f.items[key] = item
+}
+
+func getKey(stage string, id int) string {
+
+	return fmt.Sprintf("%s:%d", stage, id)
+}
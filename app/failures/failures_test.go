@@ -0,0 +1,53 @@
+package failures
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "failures.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, FileName)
+
+	f, err := Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, f.Len())
+
+	f.Add("details", 1, "http://x.com/1", "http", errors.New("Unreachable URL: http://x.com/1"))
+	f.Add("details", 2, "http://x.com/2", "timeout", errors.New("Timeout"))
+	f.Add("feed", 1, "http://x.com/rss", "decode", errors.New("Invalid"))
+	item := f.Add("details", 1, "", "http", errors.New("Unreachable URL: http://x.com/1"))
+
+	assert.Equal(t, 2, item.Attempts)
+	assert.Equal(t, "http://x.com/1", item.URL)
+	assert.Nil(t, f.Save())
+
+	f, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, f.Len())
+
+	list := f.List("details")
+	assert.Len(t, list, 2)
+	assert.Equal(t, 1, list[0].ID)
+	assert.Equal(t, 2, list[0].Attempts)
+	assert.Equal(t, "http", list[0].Class)
+	assert.Equal(t, "details", list[0].Stage)
+	assert.False(t, list[0].Time.IsZero())
+	assert.Equal(t, 2, list[1].ID)
+
+	f.Resolve("details", 1)
+	f.Resolve("details", 10)
+	assert.Len(t, f.List("details"), 1)
+	assert.Equal(t, "http://x.com/rss", f.List("feed")[0].URL)
+	assert.Nil(t, f.Save())
+
+	f, _ = Open(path)
+	assert.Equal(t, 2, f.Len())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// filterOptions are genres and shows of the config, genre names are resolved when the stage loads genres
+type filterOptions struct {
+	genres config.Filter
+	shows  config.IDFilter
+}
+
+func newFilterOptions(cfg *config.Config) *filterOptions {
+
+	return &filterOptions{genres: cfg.Genres, shows: cfg.Shows}
+}
+
+// getShowFilter resolves genre IDs and names of the options against the list of genres
+func (f *filterOptions) getShowFilter(genres []*genre.Genre) (*show.Filter, error) {
+
+	if f == nil {
+		return nil, nil
+	}
+	sel, err := genre.NewSelector(genres, f.genres.Include, f.genres.Exclude)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot apply genres filter")
+	}
+	flt := show.NewFilter(sel, f.shows.Include, f.shows.Exclude)
+	if flt.IsEmpty() {
+		return nil, nil
+	}
+	return flt, nil
+}
+
+// loadShowFilter resolves the filter with genres of the store, genres are needed only when they are selected by name
+func loadShowFilter(f *filterOptions, src static.Store) *show.Filter {
+
+	genres, err := genre.Load(src)
+	if err != nil {
+		genres = []*genre.Genre{}
+	}
+	flt, err := f.getShowFilter(genres)
+	stopOnError(err)
+	if flt != nil {
+		crawler.Infof("Filter of genres and shows is applied")
+	}
+	return flt
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/config"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetShowFilter(t *testing.T) {
+	genres := []*genre.Genre{
+		genre.NewGenre(1301, "https://podcasts.apple.com/us/genre/podcasts-arts/id1301", "Arts"),
+		genre.NewGenre(1305, "https://podcasts.apple.com/us/genre/podcasts-kids-family/id1305", "Kids & Family"),
+	}
+
+	var fo *filterOptions
+	flt, err := fo.getShowFilter(genres)
+	assert.Nil(t, err)
+	assert.Nil(t, flt)
+
+	flt, _ = newFilterOptions(config.New()).getShowFilter(genres)
+	assert.Nil(t, flt)
+
+	cfg := config.New()
+	cfg.Genres.Exclude = []string{"Kids & Family"}
+	cfg.Shows.Exclude = []int{20}
+	flt, err = newFilterOptions(cfg).getShowFilter(genres)
+	assert.Nil(t, err)
+	assert.False(t, flt.AllowsID(20))
+	assert.False(t, flt.AllowsDetails(10, &show.ShowDetails{Genres: []string{"1305"}}))
+
+	cfg.Genres.Include = []string{"News"}
+	_, err = newFilterOptions(cfg).getShowFilter(genres)
+	assert.Contains(t, err.Error(), `Genre "News" is not found`)
+}
+
+func TestActionCompactFilter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "filter.test")
+	defer os.RemoveAll(dir)
+
+	out, _ := static.OpenStore(&static.StoreOptions{Format: "jsonl", Dir: dir})
+	genre.Save(out, []*genre.Genre{{ID: 1301, Name: "Arts"}, {ID: 1305, Name: "Kids & Family"}})
+	show.Save(out, []*show.Show{{ID: 10}, {ID: 20}, {ID: 30}})
+	show.SaveDetails(out, []*show.ShowDetails{
+		{ID: 10, Genres: []string{"1301", "26"}},
+		{ID: 20, Genres: []string{"1305", "26"}},
+		{ID: 30, Genres: []string{"1301", "26"}},
+	})
+	show.SaveFeed(out, []*show.Feed{})
+
+	cfg := config.New()
+	cfg.Genres.Exclude = []string{"kids & family"}
+	cfg.Shows.Exclude = []int{30}
+	loaded, errs := actionCompact(out, out, newFilterOptions(cfg))
+	assert.Len(t, errs, 0)
+	assert.Equal(t, 1, loaded)
+
+	ids, _ := out.List(CompactKind)
+	assert.Equal(t, []int{10}, ids)
+}
@@ -1,16 +1,23 @@
 package genre
 
 import (
-	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
 	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
 )
 
+const Kind = "genres"
+
 type Genre struct {
-	ID   int
-	URL  string
-	Name string
+	ID   int    `json:"id"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
 }
 
 func NewGenre(id int, url string, name string) *Genre {
@@ -18,30 +25,39 @@ func NewGenre(id int, url string, name string) *Genre {
 	return &Genre{id, url, name}
 }
 
-func GetRequestOptions() *crawler.ScraperOptions {
+func GetRequestOptions(rule *rules.Rule, country string) *crawler.ScraperOptions {
 
-	// change the country in the request to parse country specific top
-	return crawler.GetScraperOptions(
-		[]string{"https://podcasts.apple.com/ua/genre/podcasts/id26"},
-		".top-level-genre, .top-level-subgenres a[href]",
+	// the country in the request selects the country specific top
+	return rule.GetScraperOptions(
+		[]string{fmt.Sprintf("https://podcasts.apple.com/%s/genre/podcasts/id26", country)},
 	)
 }
 
-func Save(path string, genres []*Genre) error {
+func Save(store static.Store, genres []*Genre) error {
 
-	return static.Save(path, func() ([]byte, error) {
-
-		return json.Marshal(genres)
-	})
+	if err := store.Clear(Kind); err != nil {
+		return err
+	}
+	for _, genre := range genres {
+		if err := store.Put(Kind, genre.ID, genre); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
 }
 
-func GetGenresFromFile(path string) ([]*Genre, error) {
+func Load(store static.Store) ([]*Genre, error) {
 
 	genres := []*Genre{}
 
-	err := static.Load(path, func(body []byte) error {
+	err := store.Iterate(Kind, func(id int, decode static.EntityDecoder) error {
 
-		return json.Unmarshal(body, &genres)
+		genre := &Genre{}
+		if err := decode(genre); err != nil {
+			return err
+		}
+		genres = append(genres, genre)
+		return nil
 	})
 
 	if err != nil {
@@ -51,6 +67,17 @@ func GetGenresFromFile(path string) ([]*Genre, error) {
 	return genres, nil
 }
 
+func GetGenresFromFile(path string) ([]*Genre, error) {
+
+	store, err := static.OpenFile(path)
+	if err != nil {
+		return []*Genre{}, err
+	}
+	defer store.Close()
+
+	return Load(store)
+}
+
 func GetGenres(opt *crawler.ScraperOptions) ([]*Genre, []error) {
 
 	res, err := crawler.ScrapeEntities(opt)
@@ -61,7 +88,7 @@ func GetGenres(opt *crawler.ScraperOptions) ([]*Genre, []error) {
 	genres := []*Genre{}
 	for name, url := range res {
 
-		id, err := crawler.GetEntityIDFromURL(url)
+		id, err := crawler.GetEntityIDByPattern(url, opt.IDPattern)
 		if err != nil {
 			return genres, []error{err}
 		}
@@ -70,3 +97,106 @@ func GetGenres(opt *crawler.ScraperOptions) ([]*Genre, []error) {
 
 	return genres, []error{}
 }
+
+// Selector selects genres by IDs or names along with their subgenres, excluded genres win over included ones
+type Selector struct {
+	include map[int]bool
+	exclude map[int]bool
+}
+
+// NewSelector resolves include and exclude values against the list of genres.
+// Values are genre IDs or names, names are compared case-insensitively and must exist in the list.
+func NewSelector(genres []*Genre, include []string, exclude []string) (*Selector, error) {
+
+	res := &Selector{}
+	var err error
+	if res.include, err = getSubtreeIDs(genres, include); err != nil {
+		return nil, err
+	}
+	if res.exclude, err = getSubtreeIDs(genres, exclude); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// IsEmpty checks that the selector selects every genre
+func (s *Selector) IsEmpty() bool {
+
+	return s == nil || (len(s.include) == 0 && len(s.exclude) == 0)
+}
+
+// Allows checks genre IDs of the show, the show is allowed when no ID is excluded and some ID is included
+func (s *Selector) Allows(ids []string) bool {
+
+	if s.IsEmpty() {
+		return true
+	}
+
+	included := len(s.include) == 0
+	for _, value := range ids {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		if s.exclude[id] {
+			return false
+		}
+		included = included || s.include[id]
+	}
+	return included
+}
+
+// Filter returns selected genres of the list
+func (s *Selector) Filter(genres []*Genre) []*Genre {
+
+	res := []*Genre{}
+	for _, genre := range genres {
+		if s.Allows([]string{strconv.Itoa(genre.ID)}) {
+			res = append(res, genre)
+		}
+	}
+	return res
+}
+
+// getSubtreeIDs returns IDs of the genres matching values along with IDs of their subgenres
+func getSubtreeIDs(genres []*Genre, values []string) (map[int]bool, error) {
+
+	res := map[int]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		found := false
+		for _, genre := range genres {
+			if value == strconv.Itoa(genre.ID) || strings.EqualFold(value, strings.TrimSpace(genre.Name)) {
+				found = true
+				for _, sub := range genres {
+					if sub == genre || sub.IsSubgenreOf(genre) {
+						res[sub.ID] = true
+					}
+				}
+			}
+		}
+		// IDs missing in the list are still selected, e.g. when genres are not loaded yet
+		if id, err := strconv.Atoi(value); err == nil {
+			res[id] = true
+		} else if !found {
+			return nil, errors.Errorf("Genre %q is not found in the list of genres", value)
+		}
+	}
+	return res, nil
+}
+
+// IsSubgenreOf checks the genre URL, the subgenre URL extends the parent one, e.g. podcasts-arts-books of podcasts-arts
+func (g *Genre) IsSubgenreOf(parent *Genre) bool {
+
+	slug, parentSlug := getSlug(g.URL), getSlug(parent.URL)
+	return slug != "" && parentSlug != "" && strings.HasPrefix(slug, parentSlug+"-")
+}
+
+func getSlug(url string) string {
+
+	parts := strings.Split(strings.TrimRight(url, "/"), "/")
+	if len(parts) < 2 || !strings.HasPrefix(parts[len(parts)-1], "id") {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
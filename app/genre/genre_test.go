@@ -1,7 +1,6 @@
 package genre
 
 import (
-	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/static"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -68,9 +69,11 @@ func getMockedGenres() []*Genre {
 
 func TestGetRequestOptions(t *testing.T) {
 
-	options := GetRequestOptions()
-	assert.NotEmpty(t, options.LookupURL)
+	options := GetRequestOptions(rules.Default().Get("genre"), "us")
+	assert.Equal(t, []string{"https://podcasts.apple.com/us/genre/podcasts/id26"}, options.LookupURL)
 	assert.NotEmpty(t, options.Pattern)
+	assert.NotEmpty(t, options.Script)
+	assert.NotEmpty(t, options.Match)
 }
 
 func TestGetGenres(t *testing.T) {
@@ -93,13 +96,15 @@ func TestGetGenres(t *testing.T) {
 		LookupURL: []string{ts.URL + "/invalid"},
 		Pattern:   ".target",
 	})
-	assert.Equal(t, "strconv.Atoi: parsing \"d\": invalid syntax", errors.Cause(err[0]).Error())
+	assert.Equal(t, "ID cannot be parsed from URL: http://x.com/podcasts-test1-first/idd", err[0].Error())
 
 	_, err = GetGenres(&crawler.ScraperOptions{
 		LookupURL: []string{ts.URL + "/404"},
 		Pattern:   ".target",
 	})
-	assert.Equal(t, "Not Found", errors.Cause(err[0]).Error())
+	var notFound *crawler.NotFoundError
+	assert.True(t, errors.As(err[0], &notFound))
+	assert.Equal(t, ts.URL+"/404", notFound.URL)
 }
 
 func TestGetGenresFromFile(t *testing.T) {
@@ -113,8 +118,9 @@ func TestGetGenresFromFile(t *testing.T) {
 		for i := 1; i <= 5; i++ {
 			gen = append(gen, NewGenre(i, "http://x.com", "X"))
 		}
-		json, _ := json.Marshal(gen)
-		ioutil.WriteFile("/tmp/genre.test.json", json, 0644)
+		store, _ := static.OpenFile("/tmp/genre.test.json")
+		Save(store, gen)
+		store.Close()
 	}()
 
 	gen, err = GetGenresFromFile("/tmp/genre.test.json")
@@ -123,3 +129,63 @@ func TestGetGenresFromFile(t *testing.T) {
 
 	os.Remove("/tmp/genre.test.json")
 }
+
+func TestSave(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "genre.test")
+	defer os.RemoveAll(dir)
+
+	store := static.NewJSONLStore(dir)
+	assert.Nil(t, Save(store, getMockedGenres()))
+	assert.Nil(t, Save(store, getMockedGenres()[:2]))
+
+	genres, err := Load(store)
+	assert.Nil(t, err)
+	assert.Equal(t, getMockedGenres()[:2], genres)
+	store.Close()
+
+	genres, err = GetGenresFromFile(dir + "/genres.jsonl")
+	assert.Nil(t, err)
+	assert.Equal(t, getMockedGenres()[:2], genres)
+}
+
+func TestSelector(t *testing.T) {
+
+	genres := []*Genre{
+		NewGenre(1301, "https://podcasts.apple.com/us/genre/podcasts-arts/id1301", "Arts"),
+		NewGenre(1482, "https://podcasts.apple.com/us/genre/podcasts-arts-books/id1482", "Books"),
+		NewGenre(1303, "https://podcasts.apple.com/us/genre/podcasts-comedy/id1303", "Comedy"),
+		NewGenre(1305, "https://podcasts.apple.com/us/genre/podcasts-kids-family/id1305", "Kids & Family"),
+		NewGenre(1519, "https://podcasts.apple.com/us/genre/podcasts-kids-family-stories-for-kids/id1519", "Stories for Kids"),
+	}
+
+	sel, err := NewSelector(genres, nil, nil)
+	assert.Nil(t, err)
+	assert.True(t, sel.IsEmpty())
+	assert.Len(t, sel.Filter(genres), 5)
+
+	// subgenres are selected along with the genre
+	sel, _ = NewSelector(genres, []string{"arts"}, nil)
+	assert.Equal(t, []*Genre{genres[0], genres[1]}, sel.Filter(genres))
+
+	sel, _ = NewSelector(genres, nil, []string{"Kids & Family"})
+	assert.Equal(t, genres[:3], sel.Filter(genres))
+	assert.False(t, sel.Allows([]string{"1303", "26", "1519"}))
+	assert.True(t, sel.Allows([]string{"1303", "26"}))
+
+	sel, _ = NewSelector(genres, []string{"1301", "1303"}, []string{"Books"})
+	assert.Equal(t, []*Genre{genres[0], genres[2]}, sel.Filter(genres))
+	assert.True(t, sel.Allows([]string{"1301", "26"}))
+	assert.False(t, sel.Allows([]string{"1482", "26", "1301"}))
+	assert.False(t, sel.Allows([]string{"1305"}))
+
+	// unknown IDs are kept, unknown names are errors
+	sel, err = NewSelector(nil, []string{"1301"}, nil)
+	assert.Nil(t, err)
+	assert.True(t, sel.Allows([]string{"1301"}))
+	_, err = NewSelector(genres, []string{"News"}, nil)
+	assert.Equal(t, `Genre "News" is not found in the list of genres`, err.Error())
+
+	var empty *Selector
+	assert.True(t, empty.Allows([]string{"1"}))
+}
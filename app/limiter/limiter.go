@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/lock"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPath is the state file shared by processes of the machine
+var DefaultPath = filepath.Join(os.TempDir(), "itupod.limiter.json")
+
+// lockWait is the maximal wait for the state file locked by another process
+var lockWait = 30 * time.Second
+
+// Budget is the maximal number of requests to the host per hour and per day, zero is unlimited
+type Budget struct {
+	Hourly int `json:"hourly"`
+	Daily  int `json:"daily"`
+}
+
+// Usage is the number of requests to the host in the current hour and day
+type Usage struct {
+	Next         time.Time `json:"next"`
+	Hour         string    `json:"hour"`
+	HourRequests int       `json:"hour_requests"`
+	Day          string    `json:"day"`
+	DayRequests  int       `json:"day_requests"`
+}
+
+type state struct {
+	Hosts map[string]*Usage `json:"hosts"`
+}
+
+// Limiter is the rate limiter and the request budget shared by processes through the lock-protected state file.
+// Only hosts with the interval or the budget are booked, requests to other hosts do not touch the file.
+type Limiter struct {
+	path    string
+	budgets map[string]Budget
+}
+
+func New(path string, budgets map[string]Budget) *Limiter {
+
+	return &Limiter{path: path, budgets: budgets}
+}
+
+// Reserve books the next request slot of the host and counts the request in budgets, see crawler.SharedLimiter
+func (l *Limiter) Reserve(host string, interval time.Duration, now time.Time) (time.Duration, error) {
+
+	budget, limited := l.budgets[host]
+	if interval <= 0 && !limited {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	err := l.update(func(st *state) error {
+		usage := st.get(host, now)
+		if budget.Hourly > 0 && usage.HourRequests >= budget.Hourly {
+			return &crawler.BudgetError{Host: host, Window: "hour", Limit: budget.Hourly}
+		}
+		if budget.Daily > 0 && usage.DayRequests >= budget.Daily {
+			return &crawler.BudgetError{Host: host, Window: "day", Limit: budget.Daily}
+		}
+
+		slot := now
+		if usage.Next.After(now) {
+			slot = usage.Next
+		}
+		usage.Next = slot.Add(interval)
+		usage.HourRequests++
+		usage.DayRequests++
+		wait = slot.Sub(now)
+		return nil
+	})
+	return wait, err
+}
+
+// Usage returns requests of the host in the current hour and day
+func (l *Limiter) Usage(host string, now time.Time) (*Usage, error) {
+
+	st, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	return st.get(host, now), nil
+}
+
+// Budget returns the budget of the host
+func (l *Limiter) Budget(host string) (Budget, bool) {
+
+	budget, ok := l.budgets[host]
+	return budget, ok
+}
+
+func (l *Limiter) update(fn func(st *state) error) error {
+
+	lk, err := lock.AcquireFile(l.path, lockWait)
+	if err != nil {
+		return errors.Wrap(err, "Cannot lock limiter state")
+	}
+	defer lk.Release()
+
+	st, err := l.load()
+	if err != nil {
+		return err
+	}
+	if err = fn(st); err != nil {
+		return err
+	}
+	return static.Save(l.path, func() ([]byte, error) {
+		return json.Marshal(st)
+	})
+}
+
+// load reads the state, the broken state is reset, so the crawl is not blocked by it
+func (l *Limiter) load() (*state, error) {
+
+	st := &state{Hosts: map[string]*Usage{}}
+	body, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot read limiter state")
+	}
+	if err = json.Unmarshal(body, st); err != nil || st.Hosts == nil {
+		crawler.Warnf("Limiter state %s is broken, it is reset: %v", l.path, err)
+		st.Hosts = map[string]*Usage{}
+	}
+	return st, nil
+}
+
+// get returns the usage of the host, counters of the passed hour and day are reset
+func (s *state) get(host string, now time.Time) *Usage {
+
+	usage, ok := s.Hosts[host]
+	if !ok {
+		usage = &Usage{}
+		s.Hosts[host] = usage
+	}
+
+	hour, day := now.UTC().Format("2006-01-02T15"), now.UTC().Format("2006-01-02")
+	if usage.Hour != hour {
+		usage.Hour, usage.HourRequests = hour, 0
+	}
+	if usage.Day != day {
+		usage.Day, usage.DayRequests = day, 0
+	}
+	return usage
+}
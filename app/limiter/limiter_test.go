@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserve(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "limiter.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "limiter.json")
+
+	now := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+	budgets := map[string]Budget{"itunes.apple.com": {Hourly: 2, Daily: 3}}
+
+	// limiters of different processes share slots of the host
+	first, second := New(path, budgets), New(path, budgets)
+	wait, err := first.Reserve("itunes.apple.com", time.Second, now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, err = second.Reserve("itunes.apple.com", time.Second, now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// the budget of the hour is exhausted
+	_, err = first.Reserve("itunes.apple.com", time.Second, now)
+	assert.True(t, crawler.IsBudgetError(err))
+	assert.Equal(t, "Budget of itunes.apple.com is exhausted: 2 requests per hour", err.Error())
+
+	// the next hour has the budget, but the day does not
+	_, err = first.Reserve("itunes.apple.com", time.Second, now.Add(time.Hour))
+	assert.Nil(t, err)
+	_, err = first.Reserve("itunes.apple.com", time.Second, now.Add(time.Hour))
+	assert.Equal(t, &crawler.BudgetError{Host: "itunes.apple.com", Window: "day", Limit: 3}, err)
+
+	usage, err := second.Usage("itunes.apple.com", now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, usage.HourRequests)
+	assert.Equal(t, 3, usage.DayRequests)
+
+	// hosts without the interval and the budget are not booked
+	wait, err = first.Reserve("feeds.example.com", 0, now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	usage, _ = first.Usage("feeds.example.com", now)
+	assert.Equal(t, 0, usage.DayRequests)
+}
+
+func TestReserveConcurrent(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "limiter.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "limiter.json")
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			New(path, nil).Reserve("itunes.apple.com", time.Second, now)
+		}()
+	}
+	wg.Wait()
+
+	usage, _ := New(path, nil).Usage("itunes.apple.com", now)
+	assert.Equal(t, 10, usage.DayRequests)
+	assert.Equal(t, now.Add(10*time.Second).Unix(), usage.Next.Unix())
+}
+
+func TestLoadBroken(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "limiter.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "limiter.json")
+	ioutil.WriteFile(path, []byte("{broken"), 0644)
+
+	_, err := New(path, nil).Reserve("itunes.apple.com", time.Second, time.Now())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileName is the lock file created in the locked folder
+const FileName = ".itupod.lock"
+
+// retryDelay is the delay between attempts to acquire the lock in the wait mode
+var retryDelay = 500 * time.Millisecond
+
+// LockedError is returned when the folder is locked by another process
+type LockedError struct {
+	Dir   string
+	Owner string
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner == "" {
+		return fmt.Sprintf("Folder %s is locked by another run", e.Dir)
+	}
+	return fmt.Sprintf("Folder %s is locked by another run (%s)", e.Dir, e.Owner)
+}
+
+// Lock is the advisory lock of the folder, it is held until it is released or the process exits
+type Lock struct {
+	file *os.File
+}
+
+// fileRetryDelay is the delay between attempts to acquire the lock of the file, such locks are held shortly
+var fileRetryDelay = 10 * time.Millisecond
+
+// Acquire locks the folder, when it is locked by another process the lock is awaited up to wait duration,
+// zero wait means the LockedError is returned immediately
+func Acquire(dir string, wait time.Duration) (*Lock, error) {
+
+	return acquire(filepath.Join(dir, FileName), dir, wait, retryDelay)
+}
+
+// AcquireFile locks the lock file of the path, e.g. the state shared by processes, the lock is awaited up to wait duration
+func AcquireFile(path string, wait time.Duration) (*Lock, error) {
+
+	return acquire(path+".lock", path, wait, fileRetryDelay)
+}
+
+func acquire(path string, dir string, wait time.Duration, delay time.Duration) (*Lock, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open lock file")
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Cannot lock folder")
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			owner, _ := ioutil.ReadAll(file)
+			file.Close()
+			return nil, &LockedError{Dir: dir, Owner: strings.TrimSpace(string(owner))}
+		}
+		time.Sleep(delay)
+	}
+
+	// the owner is written for the error message of other processes
+	owner := fmt.Sprintf("pid %d, since %s", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+	if err = file.Truncate(0); err == nil {
+		_, err = file.WriteAt([]byte(owner+"\n"), 0)
+	}
+	if err != nil {
+		unlock(file)
+		file.Close()
+		return nil, errors.Wrap(err, "Cannot write lock file")
+	}
+	return &Lock{file}, nil
+}
+
+// Release unlocks the folder, the lock file is kept so the other process never locks the removed file
+func (l *Lock) Release() error {
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Truncate(0)
+	if uerr := unlock(l.file); err == nil {
+		err = uerr
+	}
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	return errors.Wrap(err, "Cannot release lock")
+}
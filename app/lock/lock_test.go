@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "lock.test")
+	defer os.RemoveAll(dir)
+	retryDelay = 10 * time.Millisecond
+
+	l, err := Acquire(dir, 0)
+	assert.Nil(t, err)
+
+	_, err = Acquire(dir, 0)
+	assert.IsType(t, &LockedError{}, err)
+	assert.Contains(t, err.Error(), "is locked by another run (pid ")
+
+	start := time.Now()
+	_, err = Acquire(dir, 50*time.Millisecond)
+	assert.IsType(t, &LockedError{}, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// the waiting process gets the lock as soon as it is released
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		l.Release()
+	}()
+	next, err := Acquire(dir, time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, next.Release())
+	assert.Nil(t, next.Release())
+
+	_, err = Acquire("/not/existing/folder", 0)
+	assert.NotNil(t, err)
+}
+
+func TestAcquireFile(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "lock.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	l, err := AcquireFile(path, 0)
+	assert.Nil(t, err)
+	assert.FileExists(t, path+".lock")
+
+	_, err = AcquireFile(path, 20*time.Millisecond)
+	assert.IsType(t, &LockedError{}, err)
+
+	assert.Nil(t, l.Release())
+	l, err = AcquireFile(path, 0)
+	assert.Nil(t, err)
+	l.Release()
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package lock
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileExclusiveLock   = 0x2
+	lockfileFailImmediately = 0x1
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+func tryLock(file *os.File) (bool, error) {
+
+	ol := &syscall.Overlapped{}
+	res, _, err := procLockFileEx.Call(
+		file.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(ol)),
+	)
+	if res != 0 {
+		return true, nil
+	}
+	if err == errorLockViolation {
+		return false, nil
+	}
+	return false, err
+}
+
+func unlock(file *os.File) error {
+
+	ol := &syscall.Overlapped{}
+	res, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if res == 0 {
+		return err
+	}
+	return nil
+}
@@ -1,81 +1,180 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
-	"github.com/pkg/errors"
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+)
+
+// version is set on build, it is written to the header of generated files
+var version = "dev"
+
+// exit codes of the process
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	// exitPartial means the stage failed to load some items, loaded ones are saved
+	exitPartial = 3
 )
 
 func main() {
 
-	genFl := initBoolFlag("g", "genre", "parse genres")
-	shoFl := initBoolFlag("s", "show", "parse shows")
-	detFl := initBoolFlag("d", "detail", "parse details")
-	fedFl := initBoolFlag("f", "feed", "parse feed")
-	comFl := initBoolFlag("c", "compact", "generate compact list of shows")
+	if len(os.Args) < 2 {
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
+	}
 
-	outFl := flag.String("out", "/tmp", "generated files folder")
-	chuFl := flag.Int("chunk", 100, "details parsing chunk")
-	delFl := flag.Int("delay", 5, "delay between chunked requests")
-	flag.Parse()
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		os.Exit(actionHelp(args))
+	case "version", "-version", "--version":
+		fmt.Println(getGenerator())
+		os.Exit(exitOK)
+	}
 
-	if *genFl == true && *shoFl == true && *detFl == true && *fedFl == true {
-		stopOnError(errors.New("Invalid arguments"))
+	cmd, ok := getCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "[ERROR] Unknown command: %s\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
 	}
 
-	if *genFl == true {
+	fs := newFlagSet(cmd)
+	run := cmd.init(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		os.Exit(exitOK)
+	} else if err != nil {
+		// the error and the usage are printed by the flag set
+		os.Exit(exitUsage)
+	}
 
-		actionGenres(*outFl)
-	} else if *shoFl == true {
+	cfg, err := loadProfile(cmd, fs)
+	if err == nil {
+		err = setupProgress(fs)
+	}
+	if err == nil {
+		err = setupMetrics(fs)
+	}
+	if err == nil {
+		crawler.SetHostLimits(cfg.GetRateLimits())
+		setupLimiter(fs, cfg)
+		err = validateArgs(cmd, fs.Args())
+	}
+	if err == nil {
+		err = run(fs.Args(), cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n\n", err)
+		fs.Usage()
+		exit(exitUsage)
+	}
 
-		actionShows(getFilePathFromArg(), *outFl)
-	} else if *detFl == true {
+	exit(exitOK)
+}
 
-		actionDetails(getFilePathFromArg(), *delFl, *chuFl, *outFl)
-	} else if *fedFl == true {
+// actionHelp prints the usage of the command or the list of commands, returns the exit code
+func actionHelp(args []string) int {
 
-		actionFeed(getFilePathFromArg(), *outFl)
-	} else if *comFl == true {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
 
-		actionCompact(getFilePathFromArg(), *outFl)
+	cmd, ok := getCommand(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "[ERROR] Unknown command: %s\n", args[0])
+		return exitUsage
 	}
+	fs := newFlagSet(cmd)
+	fs.SetOutput(os.Stdout)
+	cmd.init(fs)
+	fs.Usage()
+	return exitOK
+}
 
-	fmt.Println("Done")
-	os.Exit(0)
+func getGenerator() string {
+	return "itupod " + version
 }
 
-func getFilePathFromArg() string {
-	if len(flag.Args()) == 0 || flag.Arg(0) == "" {
-		stopOnError(errors.New("File path is missing"))
+// getRefs returns references from arguments, they are read from stdin when arguments are empty or "-"
+func getRefs(args []string) []string {
+	if len(args) > 0 && args[0] != "-" {
+		return args
+	}
+
+	refs := []string{}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		refs = append(refs, scanner.Text())
 	}
-	return flag.Arg(0)
+	stopOnError(scanner.Err())
+	return refs
 }
 
-func initBoolFlag(short string, full string, desc string) *bool {
+// stopOnErrors exits with the failure code when there are errors
+func stopOnErrors(errs []error) {
 
-	var fl bool
-	flag.BoolVar(&fl, full, false, desc)
-	flag.BoolVar(&fl, short, false, desc+" (shorthand)")
-	return &fl
+	stopOnItemErrors(0, errs)
 }
 
-func stopOnErrors(errs []error) {
+// stopOnItemErrors exits when there are errors, the code is the partial failure one when some items are loaded
+func stopOnItemErrors(loaded int, errs []error) {
+
+	if current != nil {
+		current.end(loaded, errs)
+	}
 
 	if len(errs) == 0 {
 		return
 	}
+	printErrors(errs)
+	if loaded > 0 {
+		exit(exitPartial)
+	}
+	exit(exitFailure)
+}
+
+func printErrors(errs []error) {
+
 	for _, err := range errs {
-		fmt.Printf("[ERROR] %s\n", err)
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "[ERROR] Failed %d: %s\n", len(errs), getErrorsSummary(errs))
+}
+
+// getErrorsSummary returns the number of errors per class, e.g. "not_found 2, rate_limited 10"
+func getErrorsSummary(errs []error) string {
+
+	counts := map[string]int{}
+	for _, err := range errs {
+		counts[crawler.GetErrorClass(err)]++
+	}
+
+	classes := make([]string, 0, len(counts))
+	for class := range counts {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	summary := make([]string, 0, len(classes))
+	for _, class := range classes {
+		summary = append(summary, fmt.Sprintf("%s %d", class, counts[class]))
 	}
-	os.Exit(1)
+	return strings.Join(summary, ", ")
 }
 
 func stopOnError(err error) {
 
 	if err != nil {
-		fmt.Printf("[ERROR] %s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		exit(exitFailure)
 	}
 }
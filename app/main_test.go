@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetErrorsSummary(t *testing.T) {
+	errs := []error{
+		&crawler.RateLimitedError{URL: "http://x/1", Err: &crawler.HTTPStatusError{URL: "http://x/1", Code: 403}},
+		&crawler.NotFoundError{URL: "http://x/2"},
+		errors.Wrap(&crawler.RateLimitedError{URL: "http://x/3"}, "Cannot load"),
+		errors.New("Invalid entity detected"),
+	}
+	assert.Equal(t, "not_found 1, other 1, rate_limited 2", getErrorsSummary(errs))
+}
@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+const FileName = "manifest.json"
+
+// File represents the file produced by the stage, the file of the log store is listed once per kind
+type File struct {
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind"`
+	Stage    string            `json:"stage"`
+	Records  int               `json:"records"`
+	Size     int64             `json:"size"`
+	SHA256   string            `json:"sha256"`
+	Inputs   []string          `json:"inputs"`
+	Flags    map[string]string `json:"flags"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+}
+
+// Manifest lists files of the output folder along with the runs which produced them
+type Manifest struct {
+	path      string
+	Generator string    `json:"generator"`
+	Updated   time.Time `json:"updated"`
+	Files     []*File   `json:"files"`
+}
+
+// Open reads the manifest file, missing file means the empty manifest
+func Open(path string) (*Manifest, error) {
+
+	m := &Manifest{path: path, Files: []*File{}}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return m, nil
+	}
+
+	err := static.Load(path, func(body []byte) error {
+		return json.Unmarshal(body, m)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot read manifest")
+	}
+	return m, nil
+}
+
+// Add records the file of the manifest folder, the checksum is calculated from the file content.
+// Checksums of other kinds kept in the same file are updated as well.
+func (m *Manifest) Add(file *File) error {
+
+	sum, size, err := Checksum(m.Path(file.Name))
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, f := range m.Files {
+		if f.Name != file.Name {
+			continue
+		}
+		f.SHA256, f.Size = sum, size
+		if f.Kind == file.Kind {
+			m.Files[i] = file
+			replaced = true
+		}
+	}
+	if !replaced {
+		m.Files = append(m.Files, file)
+	}
+	file.SHA256, file.Size = sum, size
+	return nil
+}
+
+// Get returns the file of the kind
+func (m *Manifest) Get(kind string) (*File, bool) {
+
+	for _, f := range m.Files {
+		if f.Kind == kind {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Path returns the path of the file name in the manifest folder
+func (m *Manifest) Path(name string) string {
+
+	return filepath.Join(filepath.Dir(m.path), name)
+}
+
+func (m *Manifest) Save(generator string) error {
+
+	m.Generator = generator
+	m.Updated = time.Now().UTC()
+	return static.Save(m.path, func() ([]byte, error) {
+		return json.MarshalIndent(m, "", "  ")
+	})
+}
+
+// Verify checks that listed files exist and match their checksums
+func (m *Manifest) Verify() []error {
+
+	errs := []error{}
+	for _, f := range m.Files {
+		sum, _, err := Checksum(m.Path(f.Name))
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "Cannot verify %s", f.Name))
+		} else if sum != f.SHA256 {
+			errs = append(errs, errors.Errorf("Checksum mismatch of %s (%s): %s expected, %s found", f.Name, f.Kind, f.SHA256, sum))
+		}
+	}
+	return errs
+}
+
+// Checksum returns the SHA-256 hex digest and the size of the file as it is stored on the disk
+func Checksum(path string) (string, int64, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Cannot open file")
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Cannot read file")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
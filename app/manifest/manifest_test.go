@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "manifest.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, FileName)
+
+	m, err := Open(path)
+	assert.Nil(t, err)
+	assert.Len(t, m.Files, 0)
+
+	ioutil.WriteFile(filepath.Join(dir, "itupod.log"), []byte("genres"), 0644)
+	err = m.Add(&File{Name: "itupod.log", Kind: "genres", Stage: "genres", Records: 2, Started: time.Now()})
+	assert.Nil(t, err)
+
+	ioutil.WriteFile(filepath.Join(dir, "itupod.log"), []byte("genres shows"), 0644)
+	err = m.Add(&File{Name: "itupod.log", Kind: "shows", Stage: "shows", Records: 3, Inputs: []string{"/tmp/itupod.log"}})
+	assert.Nil(t, err)
+
+	err = m.Add(&File{Name: "missing.json", Kind: "shows.details"})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, m.Save("itupod test"))
+
+	m, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "itupod test", m.Generator)
+	assert.Len(t, m.Files, 2)
+
+	genres, ok := m.Get("genres")
+	assert.True(t, ok)
+	shows, _ := m.Get("shows")
+	assert.Equal(t, 2, genres.Records)
+	assert.Equal(t, int64(12), genres.Size)
+	assert.Equal(t, shows.SHA256, genres.SHA256)
+	assert.Equal(t, []string{"/tmp/itupod.log"}, shows.Inputs)
+	assert.Len(t, m.Verify(), 0)
+
+	_, ok = m.Get("shows.feed")
+	assert.False(t, ok)
+
+	ioutil.WriteFile(filepath.Join(dir, "itupod.log"), []byte("changed"), 0644)
+	assert.Len(t, m.Verify(), 2)
+
+	os.Remove(filepath.Join(dir, "itupod.log"))
+	assert.Len(t, m.Verify(), 2)
+}
+
+func TestChecksum(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "manifest.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.json")
+	ioutil.WriteFile(path, []byte("abc"), 0644)
+
+	sum, size, err := Checksum(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), size)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", sum)
+
+	_, _, err = Checksum(filepath.Join(dir, "b.json"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/pkg/errors"
+)
+
+// Collector counts progress events of the crawler, it is added to the progress with crawler.MultiProgress
+type Collector struct {
+	registry  *Registry
+	requests  *Counter
+	durations *Histogram
+	decode    *Counter
+	retries   *Counter
+	waits     *Histogram
+	items     *Counter
+	cache     *Counter
+	stages    *Histogram
+}
+
+// NewCollector registers metrics of the crawler in the registry
+func NewCollector(r *Registry) *Collector {
+
+	return &Collector{
+		registry:  r,
+		requests:  r.Counter("itupod_requests_total", "Requests by host and status code, the status is the error class when the response is not received.", "host", "status"),
+		durations: r.Histogram("itupod_request_duration_seconds", "Duration of requests by host.", DefaultBuckets, "host"),
+		decode:    r.Counter("itupod_decode_errors_total", "Responses which cannot be decoded by host.", "host"),
+		retries:   r.Counter("itupod_retries_total", "Items requested again after the failure by stage.", "stage"),
+		waits:     r.Histogram("itupod_limiter_wait_seconds", "Waits for rate limits and back offs by host.", DefaultBuckets, "host"),
+		items:     r.Counter("itupod_items_total", "Items by stage and result, loaded or failed.", "stage", "result"),
+		cache:     r.Counter("itupod_cache_hits_total", "Items loaded by previous runs and not requested again by stage.", "stage"),
+		stages:    r.Histogram("itupod_stage_duration_seconds", "Duration of finished stages.", DefaultBuckets, "stage"),
+	}
+}
+
+// Registry returns the registry of metrics
+func (c *Collector) Registry() *Registry {
+
+	return c.registry
+}
+
+// Emit counts the event, see crawler.Progress
+func (c *Collector) Emit(e *crawler.Event) {
+
+	switch e.Kind {
+	case crawler.EventResponse:
+		host := getHost(e.URL)
+		status := strconv.Itoa(e.Status)
+		if e.Status == 0 {
+			status = crawler.GetErrorClass(e.Err)
+		}
+		c.requests.Inc(host, status)
+		c.durations.Observe(e.Duration.Seconds(), host)
+		if e.Err != nil && crawler.GetErrorClass(e.Err) == crawler.ErrorClassDecode {
+			c.decode.Inc(host)
+		}
+	case crawler.EventWait:
+		c.waits.Observe(e.Wait.Seconds(), getHost(e.URL))
+	case crawler.EventRetry:
+		c.retries.Inc(e.Stage)
+	case crawler.EventItem:
+		result := "loaded"
+		if e.Err != nil {
+			result = "failed"
+		}
+		c.items.Inc(e.Stage, result)
+	case crawler.EventCache:
+		c.cache.Add(float64(e.Cached), e.Stage)
+	case crawler.EventStageEnd:
+		c.stages.Observe(e.Duration.Seconds(), e.Stage)
+	}
+}
+
+// Listen serves metrics of the registry at /metrics of the address until the process exits
+func Listen(addr string, r *Registry) (net.Addr, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot listen for metrics")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	go http.Serve(ln, mux)
+	return ln.Addr(), nil
+}
+
+func getHost(rawurl string) string {
+
+	parsed, err := url.Parse(rawurl)
+	if err != nil || parsed.Hostname() == "" {
+		return "unknown"
+	}
+	return parsed.Hostname()
+}
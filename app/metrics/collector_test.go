@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+
+	c := NewCollector(NewRegistry())
+	url := "https://itunes.apple.com/lookup?id=1"
+
+	c.Emit(&crawler.Event{Kind: crawler.EventResponse, URL: url, Status: 200, Duration: 300 * time.Millisecond})
+	c.Emit(&crawler.Event{Kind: crawler.EventResponse, URL: url, Status: 200, Err: &crawler.DecodeError{URL: url}})
+	c.Emit(&crawler.Event{Kind: crawler.EventResponse, URL: "https://dead.example.com/rss", Err: &crawler.TimeoutError{URL: url}})
+	c.Emit(&crawler.Event{Kind: crawler.EventWait, URL: url, Wait: 2 * time.Second})
+	c.Emit(&crawler.Event{Kind: crawler.EventRetry, Stage: "details", ID: 1})
+	c.Emit(&crawler.Event{Kind: crawler.EventItem, Stage: "details", ID: 1})
+	c.Emit(&crawler.Event{Kind: crawler.EventItem, Stage: "details", ID: 2, Err: &crawler.NotFoundError{URL: url}})
+	c.Emit(&crawler.Event{Kind: crawler.EventCache, Stage: "details", Cached: 10})
+	c.Emit(&crawler.Event{Kind: crawler.EventStageEnd, Stage: "details", Duration: time.Minute})
+	c.Emit(&crawler.Event{Kind: crawler.EventMessage, Message: "Details loaded 1"})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, c.Registry().Write(buf))
+	out := buf.String()
+	assert.Contains(t, out, `itupod_requests_total{host="itunes.apple.com",status="200"} 2`)
+	assert.Contains(t, out, `itupod_requests_total{host="dead.example.com",status="timeout"} 1`)
+	assert.Contains(t, out, `itupod_request_duration_seconds_bucket{host="itunes.apple.com",le="0.5"} 2`)
+	assert.Contains(t, out, `itupod_decode_errors_total{host="itunes.apple.com"} 1`)
+	assert.Contains(t, out, `itupod_limiter_wait_seconds_sum{host="itunes.apple.com"} 2`)
+	assert.Contains(t, out, `itupod_retries_total{stage="details"} 1`)
+	assert.Contains(t, out, `itupod_items_total{stage="details",result="loaded"} 1`)
+	assert.Contains(t, out, `itupod_items_total{stage="details",result="failed"} 1`)
+	assert.Contains(t, out, `itupod_cache_hits_total{stage="details"} 10`)
+	assert.Contains(t, out, `itupod_stage_duration_seconds_count{stage="details"} 1`)
+}
+
+func TestListen(t *testing.T) {
+
+	r := NewRegistry()
+	r.Counter("up_total", "Up.").Inc()
+
+	addr, err := Listen("127.0.0.1:0", r)
+	assert.Nil(t, err)
+
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "up_total 1")
+
+	_, err = Listen(addr.String(), r)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are upper bounds of histograms in seconds, from fast responses to long rate limit waits
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// Registry keeps metrics in the order of registration and writes them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	// value is the counter value or the sum of histogram observations
+	value  float64
+	count  uint64
+	counts []uint64
+}
+
+// Counter is the monotonically increasing value per label values
+type Counter struct {
+	r *Registry
+	m *metric
+}
+
+// Histogram counts observations per buckets and label values
+type Histogram struct {
+	r *Registry
+	m *metric
+}
+
+func NewRegistry() *Registry {
+
+	return &Registry{}
+}
+
+// Counter registers the counter with label names
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+
+	return &Counter{r, r.register(&metric{name: name, help: help, kind: "counter", labels: labels})}
+}
+
+// Histogram registers the histogram with upper bounds of buckets and label names
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{r, r.register(&metric{name: name, help: help, kind: "histogram", labels: labels, buckets: sorted})}
+}
+
+func (r *Registry) register(m *metric) *metric {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m.series = map[string]*series{}
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// Add increases the counter of label values, the number of values must match labels of the counter
+func (c *Counter) Add(delta float64, values ...string) {
+
+	if delta < 0 {
+		return
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+
+	c.m.get(values).value += delta
+}
+
+// Inc increases the counter of label values by one
+func (c *Counter) Inc(values ...string) {
+
+	c.Add(1, values...)
+}
+
+// Observe counts the value in buckets of label values
+func (h *Histogram) Observe(value float64, values ...string) {
+
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+
+	s := h.m.get(values)
+	s.value += value
+	s.count++
+	for i, bound := range h.m.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+}
+
+func (m *metric) get(values []string) *series {
+
+	key := strings.Join(values, "\x00")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: append([]string{}, values...), counts: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Write writes metrics in the Prometheus text exposition format, series are sorted by label values
+func (r *Registry) Write(w io.Writer) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.kind)
+
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := m.series[key]
+			labels := formatLabels(m.labels, s.values)
+			if m.kind == "counter" {
+				fmt.Fprintf(buf, "%s%s %s\n", m.name, wrapLabels(labels), formatValue(s.value))
+				continue
+			}
+			for i, bound := range m.buckets {
+				le := joinLabels(labels, `le="`+formatValue(bound)+`"`)
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, wrapLabels(le), s.counts[i])
+			}
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, wrapLabels(joinLabels(labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", m.name, wrapLabels(labels), formatValue(s.value))
+			fmt.Fprintf(buf, "%s_count%s %d\n", m.name, wrapLabels(labels), s.count)
+		}
+	}
+	return buf.Flush()
+}
+
+// ServeHTTP writes metrics of the registry, e.g. for /metrics path
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+func formatLabels(names []string, values []string) string {
+
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+`="`+escapeLabel(value)+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels string, label string) string {
+
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func wrapLabels(labels string) string {
+
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatValue(value float64) string {
+
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+
+	return labelReplacer.Replace(value)
+}
+
+func escapeHelp(value string) string {
+
+	return helpReplacer.Replace(value)
+}
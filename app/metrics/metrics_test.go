@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests by host.", "host", "status")
+	waits := r.Histogram("wait_seconds", "Waits.", []float64{1, 0.5})
+
+	requests.Inc("b.com", "200")
+	requests.Add(2, "a.com", "429")
+	requests.Add(-1, "a.com", "429")
+	requests.Inc("a.com", `"quoted"`)
+	waits.Observe(0.2)
+	waits.Observe(0.7)
+	waits.Observe(3)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, r.Write(buf))
+	assert.Equal(t, `# HELP requests_total Requests by host.
+# TYPE requests_total counter
+requests_total{host="a.com",status="\"quoted\""} 1
+requests_total{host="a.com",status="429"} 2
+requests_total{host="b.com",status="200"} 1
+# HELP wait_seconds Waits.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.5"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 3.9
+wait_seconds_count 3
+`, buf.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+
+	r := NewRegistry()
+	r.Counter("up_total", "Up.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "up_total 1\n")
+}
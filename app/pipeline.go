@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/manifest"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+)
+
+// pipelineStages are stages of the run command in the order of execution
+var pipelineStages = []string{stageGenres, stageShows, stageDetails, stageFeed, stageCompact}
+
+// statusFileName is the status file of the run command, it is rewritten after every stage
+const statusFileName = "run.status.json"
+
+// states of the run and its stages
+const (
+	statePending = "pending"
+	stateRunning = "running"
+	stateSkipped = "skipped"
+	stateDone    = "done"
+	statePartial = "partial"
+	stateFailed  = "failed"
+	// stateStopped means the run is interrupted, e.g. with Ctrl-C
+	stateStopped = "stopped"
+)
+
+type stageStatus struct {
+	Stage    string    `json:"stage"`
+	State    string    `json:"state"`
+	Loaded   int       `json:"loaded"`
+	Errors   int       `json:"errors"`
+	Summary  string    `json:"summary,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+type runStatus struct {
+	path    string
+	State   string         `json:"state"`
+	Started time.Time      `json:"started"`
+	Updated time.Time      `json:"updated"`
+	Stages  []*stageStatus `json:"stages"`
+}
+
+// status is the status of the running pipeline, it is saved as failed when the process exits in the middle of the stage
+var status *runStatus
+
+func newRunStatus(dir string, stages []string) *runStatus {
+
+	res := &runStatus{
+		path:    filepath.Join(dir, statusFileName),
+		State:   stateRunning,
+		Started: time.Now().UTC(),
+		Stages:  make([]*stageStatus, 0, len(stages)),
+	}
+	for _, stage := range stages {
+		res.Stages = append(res.Stages, &stageStatus{Stage: stage, State: statePending})
+	}
+	return res
+}
+
+func (s *runStatus) get(stage string) *stageStatus {
+
+	for _, st := range s.Stages {
+		if st.Stage == stage {
+			return st
+		}
+	}
+	return nil
+}
+
+func (s *runStatus) save() error {
+
+	s.Updated = time.Now().UTC()
+	return static.Save(s.path, func() ([]byte, error) {
+		return json.MarshalIndent(s, "", "  ")
+	})
+}
+
+// fail marks the running stage and the whole run as failed
+func (s *runStatus) fail() error {
+
+	for _, st := range s.Stages {
+		if st.State == stateRunning {
+			st.State = stateFailed
+			st.Finished = time.Now().UTC()
+		}
+	}
+	s.State = stateFailed
+	return s.save()
+}
+
+type pipelineOptions struct {
+	stages []string
+	rules  *rules.Rules
+	delay  int
+	// minDelay and maxDelay are bounds of the adaptive delay of lookup requests
+	minDelay time.Duration
+	maxDelay time.Duration
+	chunk    int
+	ttl      time.Duration
+	fresh    time.Duration
+	// filter selects genres and shows of shows, details, feed and compact stages
+	filter *filterOptions
+}
+
+// getPipelineStages returns stages from the first to the last one inclusive
+func getPipelineStages(from string, until string) ([]string, error) {
+
+	start, end := -1, -1
+	for i, stage := range pipelineStages {
+		if stage == from {
+			start = i
+		}
+		if stage == until {
+			end = i
+		}
+	}
+
+	names := strings.Join(pipelineStages, ", ")
+	if start < 0 {
+		return nil, usageErrorf("Invalid value of -from flag: %s, expected one of: %s", from, names)
+	}
+	if end < 0 {
+		return nil, usageErrorf("Invalid value of -until flag: %s, expected one of: %s", until, names)
+	}
+	if start > end {
+		return nil, usageErrorf("Stage %s of -from flag goes after stage %s of -until flag", from, until)
+	}
+	return pipelineStages[start : end+1], nil
+}
+
+// actionRun runs stages one after another, returns the exit code.
+// Stages with fresh outputs are skipped unless the previous stage changed them, details are loaded until the queue is done.
+func actionRun(fs *flag.FlagSet, popt *pipelineOptions, opt *static.StoreOptions, out static.Store, fails *failures.File) int {
+	crawler.Infof("Starting run of %s", strings.Join(popt.stages, ", "))
+	m, err := manifest.Open(filepath.Join(opt.Dir, manifest.FileName))
+	stopOnError(err)
+
+	status = newRunStatus(opt.Dir, popt.stages)
+	stopOnError(status.save())
+
+	// the interrupted run stops after the current stage, details are stopped after the current request
+	done, stop := notifyStop("Stopping run after the current stage")
+	defer stop()
+
+	code := exitOK
+	changed := false
+	runs := []*stageRun{}
+	for _, stage := range popt.stages {
+		if isStopped(done) {
+			code = exitFailure
+			status.State = stateStopped
+			break
+		}
+
+		st := status.get(stage)
+		if !changed && isStageFresh(m, opt, stage, popt.fresh, time.Now().UTC()) {
+			crawler.Infof("Stage %s is fresh, skipped", stage)
+			st.State = stateSkipped
+			stopOnError(status.save())
+			continue
+		}
+
+		st.State = stateRunning
+		st.Started = time.Now().UTC()
+		stopOnError(status.save())
+
+		inputs := getStageInputs(opt, stage)
+		current = newStageRun(fs, stage, inputs, opt, out, fails)
+		loaded, errs := runStage(stage, popt, inputs, opt, out, fails)
+		current.end(loaded, errs)
+		stopOnError(current.record())
+		runs = append(runs, current)
+		current = nil
+
+		st.Finished = time.Now().UTC()
+		st.Loaded = loaded
+		st.Errors = len(errs)
+		st.State = stateDone
+		if len(errs) > 0 {
+			printErrors(errs)
+			st.Summary = getErrorsSummary(errs)
+			st.State = statePartial
+			code = exitPartial
+		}
+		if len(errs) > 0 && loaded == 0 {
+			st.State = stateFailed
+			code = exitFailure
+		}
+		stopOnError(status.save())
+		if code == exitFailure {
+			break
+		}
+		changed = changed || loaded > 0
+	}
+	if isStopped(done) && code != exitFailure {
+		code = exitFailure
+		status.State = stateStopped
+	}
+
+	stopOnError(out.Close())
+	// checksums are updated, closed files may be different, e.g. compressed ones
+	for _, run := range runs {
+		stopOnError(run.record())
+	}
+
+	switch {
+	case status.State == stateStopped:
+	case code == exitOK:
+		status.State = stateDone
+	case code == exitPartial:
+		status.State = statePartial
+	default:
+		status.State = stateFailed
+	}
+	stopOnError(status.save())
+	status = nil
+	return code
+}
+
+func runStage(stage string, popt *pipelineOptions, inputs []string, opt *static.StoreOptions, out static.Store, fails *failures.File) (int, []error) {
+	switch stage {
+	case stageGenres:
+		return actionGenres(popt.rules, opt.Country, out, fails)
+	case stageShows:
+		return actionShows(inputs[0], popt.rules, popt.filter, out, fails)
+	case stageDetails:
+		th := crawler.NewThrottle(time.Duration(popt.delay)*time.Second, popt.minDelay, popt.maxDelay)
+		return actionDetails(inputs[0], th, popt.chunk, true, popt.filter, opt.Dir, out, fails)
+	case stageFeed:
+		return actionFeed(inputs[0], popt.ttl, popt.filter, out, fails)
+	case stageCompact:
+		// source files are read in the format and compression they have on disk, not the output ones
+		src := static.OpenSource(opt.Dir, out)
+		defer src.Close()
+		return actionCompact(src, out, popt.filter)
+	}
+	return 0, []error{}
+}
+
+// getStageInputs returns paths of files the stage reads from the output folder
+func getStageInputs(opt *static.StoreOptions, stage string) []string {
+	kinds := map[string]string{
+		stageShows:   genre.Kind,
+		stageDetails: show.Kind,
+		stageFeed:    show.DetailsKind,
+	}
+	kind, ok := kinds[stage]
+	if !ok {
+		return []string{}
+	}
+	name, err := opt.FileName(kind)
+	stopOnError(err)
+	return []string{filepath.Join(opt.Dir, name)}
+}
+
+// isStageFresh checks that the stage output of the manifest is produced within maxAge and is not changed since then.
+// Details are never fresh, the queue knows which shows are left.
+func isStageFresh(m *manifest.Manifest, opt *static.StoreOptions, stage string, maxAge time.Duration, now time.Time) bool {
+	if stage == stageDetails || maxAge <= 0 {
+		return false
+	}
+
+	kind := stageKinds[stage][0]
+	file, ok := m.Get(kind)
+	if !ok || now.Sub(file.Finished) > maxAge {
+		return false
+	}
+	if name, err := opt.FileName(kind); err != nil || name != file.Name {
+		return false
+	}
+
+	sum, _, err := manifest.Checksum(m.Path(file.Name))
+	return err == nil && sum == file.SHA256
+}
+
+// saveStatusOnExit marks the running pipeline as failed, so the status file does not stay running
+func saveStatusOnExit() {
+	if st := status; st != nil {
+		status = nil
+		if err := st.fail(); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Cannot write status: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/manifest"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPipelineStages(t *testing.T) {
+	stages, err := getPipelineStages("genres", "compact")
+	assert.Nil(t, err)
+	assert.Equal(t, pipelineStages, stages)
+
+	stages, err = getPipelineStages("details", "feed")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"details", "feed"}, stages)
+
+	stages, _ = getPipelineStages("shows", "shows")
+	assert.Equal(t, []string{"shows"}, stages)
+
+	_, err = getPipelineStages("pages", "compact")
+	assert.IsType(t, &usageError{}, err)
+
+	_, err = getPipelineStages("genres", "lookup")
+	assert.IsType(t, &usageError{}, err)
+
+	_, err = getPipelineStages("feed", "shows")
+	assert.Equal(t, "Stage feed of -from flag goes after stage shows of -until flag", err.Error())
+}
+
+func TestIsStageFresh(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pipeline.test")
+	defer os.RemoveAll(dir)
+
+	opt := &static.StoreOptions{Format: "json", Dir: dir}
+	now := time.Now().UTC()
+	ioutil.WriteFile(filepath.Join(dir, "genres.json"), []byte("genres"), 0644)
+
+	m, _ := manifest.Open(filepath.Join(dir, manifest.FileName))
+	assert.False(t, isStageFresh(m, opt, stageGenres, time.Hour, now))
+
+	m.Add(&manifest.File{Name: "genres.json", Kind: genre.Kind, Finished: now.Add(-time.Minute)})
+	assert.True(t, isStageFresh(m, opt, stageGenres, time.Hour, now))
+	assert.False(t, isStageFresh(m, opt, stageGenres, 0, now))
+	assert.False(t, isStageFresh(m, opt, stageGenres, time.Second, now))
+	assert.False(t, isStageFresh(m, &static.StoreOptions{Format: "jsonl", Dir: dir}, stageGenres, time.Hour, now))
+
+	// the changed output is not fresh
+	ioutil.WriteFile(filepath.Join(dir, "genres.json"), []byte("changed"), 0644)
+	assert.False(t, isStageFresh(m, opt, stageGenres, time.Hour, now))
+
+	m.Add(&manifest.File{Name: "genres.json", Kind: show.DetailsKind, Finished: now})
+	assert.False(t, isStageFresh(m, opt, stageDetails, time.Hour, now))
+}
+
+func TestActionRun(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pipeline.test")
+	defer os.RemoveAll(dir)
+
+	opt := &static.StoreOptions{Format: "jsonl", Dir: dir}
+	out, _ := static.OpenStore(opt)
+	genre.Save(out, []*genre.Genre{{ID: 1, Name: "Arts"}})
+	show.Save(out, []*show.Show{{ID: 10}, {ID: 20}})
+	show.SaveDetails(out, []*show.ShowDetails{{ID: 10, Name: "Show", Genres: []string{"1"}}})
+	show.SaveFeed(out, []*show.Feed{{ID: 10, Language: "en", FetchedAt: time.Now()}})
+	fails, _ := failures.Open(filepath.Join(dir, failures.FileName))
+
+	popt := &pipelineOptions{stages: []string{stageCompact}, fresh: time.Hour}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	assert.Equal(t, exitOK, actionRun(fs, popt, opt, out, fails))
+
+	st := &runStatus{}
+	body, _ := ioutil.ReadFile(filepath.Join(dir, statusFileName))
+	assert.Nil(t, json.Unmarshal(body, st))
+	assert.Equal(t, stateDone, st.State)
+	assert.Len(t, st.Stages, 1)
+	assert.Equal(t, stateDone, st.Stages[0].State)
+	assert.Equal(t, 1, st.Stages[0].Loaded)
+
+	m, _ := manifest.Open(filepath.Join(dir, manifest.FileName))
+	compact, ok := m.Get(CompactKind)
+	assert.True(t, ok)
+	assert.Equal(t, "shows.compact.jsonl", compact.Name)
+	assert.Len(t, m.Verify(), 0)
+
+	// the second run skips the fresh stage
+	out, _ = static.OpenStore(opt)
+	assert.Equal(t, exitOK, actionRun(fs, popt, opt, out, fails))
+	body, _ = ioutil.ReadFile(filepath.Join(dir, statusFileName))
+	assert.Nil(t, json.Unmarshal(body, st))
+	assert.Equal(t, stateSkipped, st.Stages[0].State)
+}
+
+func TestRunStatusFail(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pipeline.test")
+	defer os.RemoveAll(dir)
+
+	st := newRunStatus(dir, []string{stageGenres, stageShows})
+	st.get(stageGenres).State = stateDone
+	st.get(stageShows).State = stateRunning
+	assert.Nil(t, st.fail())
+
+	res := &runStatus{}
+	body, _ := ioutil.ReadFile(filepath.Join(dir, statusFileName))
+	assert.Nil(t, json.Unmarshal(body, res))
+	assert.Equal(t, stateFailed, res.State)
+	assert.Equal(t, stateDone, res.Stages[0].State)
+	assert.Equal(t, stateFailed, res.Stages[1].State)
+	assert.Nil(t, st.get("feed"))
+}
@@ -0,0 +1,119 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Plan describes requests the stage would send, it is built from local files without network requests
+type Plan struct {
+	Stage string
+	// Items is the number of items of the stage input
+	Items int
+	// Cached is the number of items loaded before, they are not requested again
+	Cached int
+	// Filtered is the number of items left out by filters
+	Filtered int
+	URLs     []string
+	// Delay is the delay between sequential requests, concurrent requests are limited by host limits only
+	Delay      time.Duration
+	Concurrent bool
+	// Notes explain the plan, e.g. the stage is skipped or its input is not loaded yet
+	Notes []string
+}
+
+// Host is the share of the plan requests sent to the single host
+type Host struct {
+	Name     string
+	Requests int
+	// Interval is the expected time between requests to the host
+	Interval time.Duration
+	ETA      time.Duration
+}
+
+func New(stage string) *Plan {
+
+	return &Plan{Stage: stage, URLs: []string{}, Notes: []string{}}
+}
+
+// Note adds the remark to the plan
+func (p *Plan) Note(format string, args ...interface{}) {
+
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+// Hosts groups requests by hosts, hosts with more requests go first.
+// Sequential requests wait for the delay or the host limit whichever is longer, concurrent ones wait for the host limit.
+func (p *Plan) Hosts(limits map[string]time.Duration) []*Host {
+
+	hosts := map[string]*Host{}
+	for _, rawurl := range p.URLs {
+		name := rawurl
+		if parsed, err := url.Parse(rawurl); err == nil && parsed.Hostname() != "" {
+			name = parsed.Hostname()
+		}
+		if _, ok := hosts[name]; !ok {
+			hosts[name] = &Host{Name: name, Interval: p.getInterval(limits[name])}
+		}
+		hosts[name].Requests++
+	}
+
+	res := make([]*Host, 0, len(hosts))
+	for _, host := range hosts {
+		host.ETA = time.Duration(host.Requests) * host.Interval
+		res = append(res, host)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Requests != res[j].Requests {
+			return res[i].Requests > res[j].Requests
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// ETA estimates the duration of the stage by delays and host limits, response times are not counted
+func (p *Plan) ETA(limits map[string]time.Duration) time.Duration {
+
+	var res time.Duration
+	for _, host := range p.Hosts(limits) {
+		switch {
+		case !p.Concurrent:
+			res += host.ETA
+		case host.ETA > res:
+			res = host.ETA
+		}
+	}
+	return res
+}
+
+// Print writes the plan in the human readable form
+func (p *Plan) Print(w io.Writer, limits map[string]time.Duration) {
+
+	mode := "sequential, delay " + p.Delay.String()
+	if p.Concurrent {
+		mode = "concurrent"
+	}
+
+	fmt.Fprintf(w, "Plan of %s stage\n", p.Stage)
+	fmt.Fprintf(w, "  Items %d, cached %d, filtered out %d\n", p.Items, p.Cached, p.Filtered)
+	fmt.Fprintf(w, "  Requests %d (%s)\n", len(p.URLs), mode)
+	for _, host := range p.Hosts(limits) {
+		fmt.Fprintf(w, "    %s: %d requests, interval %s, ETA %s\n", host.Name, host.Requests, host.Interval, host.ETA)
+	}
+	fmt.Fprintf(w, "  ETA %s\n", p.ETA(limits))
+	for _, note := range p.Notes {
+		fmt.Fprintf(w, "  Note: %s\n", note)
+	}
+}
+
+func (p *Plan) getInterval(limit time.Duration) time.Duration {
+
+	if !p.Concurrent && p.Delay > limit {
+		return p.Delay
+	}
+	return limit
+}
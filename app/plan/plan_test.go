@@ -0,0 +1,51 @@
+package plan
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHosts(t *testing.T) {
+
+	p := New("details")
+	p.Delay = 2 * time.Second
+	p.URLs = []string{
+		"https://itunes.apple.com/lookup?id=1",
+		"https://itunes.apple.com/lookup?id=2",
+		"https://podcasts.apple.com/us/podcast/id3",
+	}
+	limits := map[string]time.Duration{"podcasts.apple.com": 5 * time.Second}
+
+	hosts := p.Hosts(limits)
+	assert.Equal(t, []*Host{
+		{Name: "itunes.apple.com", Requests: 2, Interval: 2 * time.Second, ETA: 4 * time.Second},
+		{Name: "podcasts.apple.com", Requests: 1, Interval: 5 * time.Second, ETA: 5 * time.Second},
+	}, hosts)
+	assert.Equal(t, 9*time.Second, p.ETA(limits))
+
+	// concurrent requests wait for host limits only
+	p.Concurrent = true
+	assert.Equal(t, 5*time.Second, p.ETA(limits))
+	assert.Equal(t, time.Duration(0), p.ETA(nil))
+}
+
+func TestPrint(t *testing.T) {
+
+	p := New("feed")
+	p.Items, p.Cached, p.Filtered = 3, 1, 1
+	p.Concurrent = true
+	p.URLs = []string{"https://feeds.example.com/rss"}
+	p.Note("Feeds fetched within %s are cached", time.Hour)
+
+	buf := &bytes.Buffer{}
+	p.Print(buf, map[string]time.Duration{"feeds.example.com": time.Second})
+	assert.Equal(t, "Plan of feed stage\n"+
+		"  Items 3, cached 1, filtered out 1\n"+
+		"  Requests 1 (concurrent)\n"+
+		"    feeds.example.com: 1 requests, interval 1s, ETA 1s\n"+
+		"  ETA 1s\n"+
+		"  Note: Feeds fetched within 1h0m0s are cached\n", buf.String())
+}
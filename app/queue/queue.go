@@ -0,0 +1,356 @@
+package queue
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Pending = "pending"
+	Done    = "done"
+	Failed  = "failed"
+	// Skipped IDs are left out by filters, they are pending again when added back
+	Skipped = "skipped"
+)
+
+const itemKind = "queue"
+
+// Item represents the state of the single queued ID, done items keep the result
+type Item struct {
+	ID       int             `json:"id"`
+	State    string          `json:"state"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Updated  time.Time       `json:"updated"`
+}
+
+// Queue is the persistent queue of IDs backed by the append-only log,
+// every state change is synced to the disk before the method returns
+type Queue struct {
+	mu   sync.Mutex
+	path string
+	log  *static.LogStore
+	// broken is set when the log cannot be opened again after the compaction, every call fails with it then
+	broken error
+	order  []int
+	items  map[int]*Item
+}
+
+func Open(path string) (*Queue, error) {
+
+	log, err := static.NewLogStore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open queue")
+	}
+
+	q := &Queue{path: path, log: log, order: []int{}, items: map[int]*Item{}}
+	err = log.Iterate(itemKind, func(id int, decode static.EntityDecoder) error {
+		item := &Item{}
+		if err := decode(item); err != nil {
+			return err
+		}
+		// results are kept in the log only
+		item.Result = nil
+		q.order = append(q.order, id)
+		q.items[id] = item
+		return nil
+	})
+	if err != nil {
+		log.Close()
+		return nil, errors.Wrap(err, "Cannot read queue")
+	}
+
+	return q, nil
+}
+
+// Add enqueues unknown and skipped IDs as pending, returns the number of added IDs
+func (q *Queue) Add(ids ...int) (int, error) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	added := 0
+	for _, id := range ids {
+		item, ok := q.items[id]
+		if ok && item.State != Skipped {
+			continue
+		}
+		if err := q.put(&Item{ID: id, State: Pending, Attempts: q.item(id).Attempts}); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, q.flush()
+}
+
+// MarkDone marks unknown IDs as done without the result, e.g. IDs loaded before the queue was created
+func (q *Queue) MarkDone(ids ...int) (int, error) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	added := 0
+	for _, id := range ids {
+		if _, ok := q.items[id]; ok {
+			continue
+		}
+		if err := q.put(&Item{ID: id, State: Done}); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, q.flush()
+}
+
+// Skip marks pending and failed IDs as skipped, returns the number of skipped IDs
+func (q *Queue) Skip(ids ...int) (int, error) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	skipped := 0
+	for _, id := range ids {
+		item, ok := q.items[id]
+		if !ok || (item.State != Pending && item.State != Failed) {
+			continue
+		}
+		if err := q.put(&Item{ID: id, State: Skipped, Attempts: item.Attempts, Error: item.Error}); err != nil {
+			return skipped, err
+		}
+		skipped++
+	}
+	return skipped, q.flush()
+}
+
+// Next returns up to n pending IDs followed by failed IDs with less than maxAttempts attempts
+func (q *Queue) Next(n int, maxAttempts int) []int {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	res := []int{}
+	for _, state := range []string{Pending, Failed} {
+		for _, id := range q.order {
+			if len(res) >= n {
+				return res
+			}
+			item := q.items[id]
+			if item.State == state && (state == Pending || item.Attempts < maxAttempts) {
+				res = append(res, id)
+			}
+		}
+	}
+	return res
+}
+
+// Done marks the ID as done and stores the result along with it
+func (q *Queue) Done(id int, result interface{}) error {
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode result")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item := q.item(id)
+	if err = q.put(&Item{ID: id, State: Done, Attempts: item.Attempts + 1, Result: raw}); err != nil {
+		return err
+	}
+	return q.flush()
+}
+
+// Fail marks the ID as failed with the error
+func (q *Queue) Fail(id int, cause error) error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item := q.item(id)
+	if err := q.put(&Item{ID: id, State: Failed, Attempts: item.Attempts + 1, Error: cause.Error()}); err != nil {
+		return err
+	}
+	return q.flush()
+}
+
+// Result decodes the result of the done ID
+func (q *Queue) Result(id int, result interface{}) error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.broken != nil {
+		return q.broken
+	}
+	item := &Item{}
+	if err := q.log.Get(itemKind, id, item); err != nil {
+		return err
+	}
+	if item.State != Done || len(item.Result) == 0 {
+		return static.ErrNotFound
+	}
+	return json.Unmarshal(item.Result, result)
+}
+
+// Get returns the state of the ID without the result
+func (q *Queue) Get(id int) (*Item, bool) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return nil, false
+	}
+	res := *item
+	return &res, true
+}
+
+// IDs returns all queued IDs in the order of adding
+func (q *Queue) IDs() []int {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]int{}, q.order...)
+}
+
+// Stats returns the number of IDs per state
+func (q *Queue) Stats() map[string]int {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	res := map[string]int{Pending: 0, Done: 0, Failed: 0, Skipped: 0}
+	for _, item := range q.items {
+		res[item.State]++
+	}
+	return res
+}
+
+// Finished reports whether the queue has no pending IDs and no failed IDs with less than maxAttempts attempts
+func (q *Queue) Finished(maxAttempts int) bool {
+
+	return len(q.Next(1, maxAttempts)) == 0
+}
+
+// Compact rewrites the log with the latest record of every ID, so repeated state changes do not grow the log.
+// Results of done IDs are kept, the current log stays as it is when the compacted one cannot be written.
+func (q *Queue) Compact() error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.broken != nil {
+		return q.broken
+	}
+
+	tmp := q.path + ".tmp"
+	if err := q.writeCompacted(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := q.log.Close(); err != nil {
+		os.Remove(tmp)
+		return q.reopen(errors.Wrap(err, "Cannot close queue"))
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		os.Remove(tmp)
+		return q.reopen(errors.Wrap(err, "Cannot replace queue"))
+	}
+	return q.reopen(static.SyncDir(filepath.Dir(q.path)))
+}
+
+// writeCompacted writes the latest record of every ID to the new log
+func (q *Queue) writeCompacted(path string) error {
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Cannot remove compacted queue")
+	}
+	log, err := static.NewLogStore(path)
+	if err != nil {
+		return errors.Wrap(err, "Cannot create compacted queue")
+	}
+
+	for _, id := range q.order {
+		item := &Item{}
+		if err = q.log.Get(itemKind, id, item); err == nil {
+			err = log.Put(itemKind, id, item)
+		}
+		if err != nil {
+			log.Close()
+			return errors.Wrap(err, "Cannot compact queue")
+		}
+	}
+	return errors.Wrap(log.Close(), "Cannot compact queue")
+}
+
+// reopen opens the log after the compaction, either the compacted or the old one, and returns the cause.
+// The queue is broken when the log cannot be opened.
+func (q *Queue) reopen(cause error) error {
+
+	log, err := static.NewLogStore(q.path)
+	if err != nil {
+		q.log = nil
+		q.broken = errors.Wrapf(err, "Queue %s cannot be used after the compaction", q.path)
+		return q.broken
+	}
+	q.log = log
+	return cause
+}
+
+func (q *Queue) Close() error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.log == nil {
+		return nil
+	}
+	return q.log.Close()
+}
+
+func (q *Queue) flush() error {
+
+	if q.broken != nil {
+		return q.broken
+	}
+	return q.log.Flush()
+}
+
+func (q *Queue) item(id int) *Item {
+
+	if item, ok := q.items[id]; ok {
+		return item
+	}
+	return &Item{ID: id}
+}
+
+func (q *Queue) put(item *Item) error {
+
+	if q.broken != nil {
+		return q.broken
+	}
+	item.Updated = time.Now().UTC()
+	if err := q.log.Put(itemKind, item.ID, item); err != nil {
+		return err
+	}
+
+	if _, ok := q.items[item.ID]; !ok {
+		q.order = append(q.order, item.ID)
+	}
+	state := *item
+	state.Result = nil
+	q.items[item.ID] = &state
+	return nil
+}
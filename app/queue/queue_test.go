@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testResult struct {
+	ID   int
+	Name string
+}
+
+func TestQueue(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "queue.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.queue")
+
+	q, err := Open(path)
+	assert.Nil(t, err)
+
+	added, err := q.Add(1, 2, 3, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, added)
+
+	added, _ = q.Add(1, 5)
+	assert.Equal(t, 1, added)
+
+	added, _ = q.MarkDone(5, 6)
+	assert.Equal(t, 1, added)
+
+	assert.Equal(t, []int{1, 2}, q.Next(2, 3))
+
+	assert.Nil(t, q.Done(1, &testResult{1, "one"}))
+	assert.Nil(t, q.Fail(2, errors.New("Failed")))
+	assert.Nil(t, q.Close())
+
+	// state survives the restart
+	q, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{Pending: 3, Done: 2, Failed: 1, Skipped: 0}, q.Stats())
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, q.IDs())
+	assert.Equal(t, []int{3, 4, 5, 2}, q.Next(10, 3))
+
+	res := &testResult{}
+	assert.Nil(t, q.Result(1, res))
+	assert.Equal(t, &testResult{1, "one"}, res)
+	assert.Equal(t, static.ErrNotFound, q.Result(2, res))
+	assert.Equal(t, static.ErrNotFound, q.Result(6, res))
+
+	item, ok := q.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, Failed, item.State)
+	assert.Equal(t, 1, item.Attempts)
+	assert.Equal(t, "Failed", item.Error)
+
+	// failed IDs are retried until the attempts limit
+	assert.Contains(t, q.Next(10, 2), 2)
+	assert.Nil(t, q.Fail(2, errors.New("Failed again")))
+	assert.NotContains(t, q.Next(10, 2), 2)
+
+	_, ok = q.Get(10)
+	assert.False(t, ok)
+
+	// skipped IDs are not returned until they are added back
+	skipped, err := q.Skip(1, 3, 4, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, skipped)
+	assert.Equal(t, []int{5}, q.Next(10, 2))
+	added, _ = q.Add(1, 3)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, []int{3, 5}, q.Next(10, 2))
+	q.Close()
+}
+
+func TestQueueCompact(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "queue.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.queue")
+
+	q, err := Open(path)
+	assert.Nil(t, err)
+	q.Add(1, 2)
+	assert.False(t, q.Finished(3))
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, q.Done(1, &testResult{1, "one"}))
+		assert.Nil(t, q.Fail(2, errors.New("Failed")))
+	}
+	assert.True(t, q.Finished(3))
+
+	before, _ := os.Stat(path)
+	assert.Nil(t, q.Compact())
+	after, _ := os.Stat(path)
+	assert.True(t, after.Size() < before.Size())
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	// the compacted queue keeps working
+	q.Add(3)
+	assert.Nil(t, q.Done(3, &testResult{3, "three"}))
+	assert.Nil(t, q.Close())
+
+	q, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, q.IDs())
+	assert.Equal(t, 2, q.Stats()[Done])
+
+	res := &testResult{}
+	assert.Nil(t, q.Result(1, res))
+	assert.Equal(t, &testResult{1, "one"}, res)
+	item, _ := q.Get(2)
+	assert.Equal(t, 10, item.Attempts)
+
+	// the queue whose log cannot be opened again fails every call
+	os.Remove(path)
+	os.Mkdir(path, 0755)
+	assert.NotNil(t, q.Compact())
+	assert.Contains(t, q.Done(1, &testResult{1, "one"}).Error(), "cannot be used after the compaction")
+	assert.NotNil(t, q.Result(1, res))
+	assert.Nil(t, q.Close())
+}
@@ -0,0 +1,110 @@
+package rules
+
+import "github.com/zhikiri/itunes.podcasts/app/crawler"
+
+const (
+	CheckOK       = "ok"
+	CheckEmpty    = "empty"
+	CheckAbnormal = "abnormal"
+	CheckError    = "error"
+)
+
+// CheckResult represents the health of the single rule on the sample page.
+// Selector and embedded data are checked separately, the rule is OK when either of them is OK.
+type CheckResult struct {
+	Entity         string
+	URL            string
+	Matched        int
+	Embedded       int
+	SelectorStatus string
+	// EmbeddedStatus is empty when the rule has no script
+	EmbeddedStatus string
+	Status         string
+	Error          error
+}
+
+// Check fetches sample pages of every rule and counts entities whose IDs are parsed
+func Check(rules *Rules) []*CheckResult {
+
+	results := []*CheckResult{}
+	for _, name := range rules.names() {
+		results = append(results, checkRule(name, rules.Entities[name])...)
+	}
+	return results
+}
+
+func checkRule(name string, rule *Rule) []*CheckResult {
+
+	// rules which cannot be compiled are reported once without fetching samples
+	if err := rule.compile(); err != nil {
+		res := &CheckResult{Entity: name, Status: CheckError, SelectorStatus: CheckError, Error: err}
+		if rule.Script != "" {
+			res.EmbeddedStatus = CheckError
+		}
+		return []*CheckResult{res}
+	}
+
+	selectors := map[string]string{"selector": rule.Selector}
+	if rule.Script != "" {
+		selectors["script"] = rule.Script
+	}
+
+	results := []*CheckResult{}
+	opt := crawler.GetPageScraperOptions(rule.Samples, selectors, 0)
+	opt.Attribute = rule.Attribute
+	if opt.Attribute == "" {
+		opt.Attribute = "href"
+	}
+	out := crawler.ScrapePages(opt)
+	for page := range out {
+		res := &CheckResult{Entity: name, URL: page.URL, Error: page.Error}
+		if page.Error != nil {
+			res.Status, res.SelectorStatus = CheckError, CheckError
+			if rule.Script != "" {
+				res.EmbeddedStatus = CheckError
+			}
+			results = append(results, res)
+			continue
+		}
+
+		// elements and embedded links count only when the stage would parse the entity ID from them
+		for _, el := range page.Fields["selector"] {
+			if rule.parses(el.Attr) {
+				res.Matched++
+			}
+		}
+		scripts := []string{}
+		for _, el := range page.Fields["script"] {
+			scripts = append(scripts, el.Text)
+		}
+		if embedded, err := crawler.GetEmbeddedEntities(scripts, rule.match); err == nil {
+			for _, url := range embedded {
+				if rule.parses(url) {
+					res.Embedded++
+				}
+			}
+		}
+
+		res.SelectorStatus = getCheckStatus(rule, res.Matched)
+		res.Status = res.SelectorStatus
+		if rule.Script != "" {
+			res.EmbeddedStatus = getCheckStatus(rule, res.Embedded)
+			if res.EmbeddedStatus == CheckOK {
+				res.Status = CheckOK
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func getCheckStatus(rule *Rule, found int) string {
+
+	if found == 0 {
+		return CheckEmpty
+	}
+	if found < rule.Min || (rule.Max > 0 && found > rule.Max) {
+		return CheckAbnormal
+	}
+	return CheckOK
+}
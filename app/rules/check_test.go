@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte(`<!DOCTYPE html>
+<html>
+<body>
+<script id="data">[{"title": "Show", "url": "http://x.com/podcast/show/id1"}]</script>
+<a class="target" href="http://x.com/podcast/test1/id1">link #1</a>
+<a class="target" href="http://x.com/podcast/test2/id2">link #2</a>
+<a class="target" href="http://x.com/podcasts/about">about</a>
+</body>
+</html>
+		`))
+	})
+
+	mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(404)
+		w.Write([]byte("<p>error</p>"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestCheck(t *testing.T) {
+
+	ts := newTestServer()
+	defer ts.Close()
+
+	rules := &Rules{Entities: map[string]*Rule{
+		"a.ok":       &Rule{Selector: ".target", Samples: []string{ts.URL}, Min: 1, Max: 5},
+		"b.empty":    &Rule{Selector: ".missing", Samples: []string{ts.URL}},
+		"c.abnormal": &Rule{Selector: ".target", Samples: []string{ts.URL}, Min: 10},
+		"d.error":    &Rule{Selector: ".target", Samples: []string{ts.URL + "/404"}},
+		"e.embedded": &Rule{
+			Selector: ".target",
+			Script:   "script#data",
+			Match:    `/genre/`,
+			Samples:  []string{ts.URL},
+		},
+		"f.embedded": &Rule{
+			Selector: ".missing",
+			Script:   "script#data",
+			Match:    `/podcast/`,
+			Samples:  []string{ts.URL},
+		},
+		"g.embedded": &Rule{
+			Selector: ".missing",
+			Script:   "script#data",
+			Match:    `/genre/`,
+			Samples:  []string{ts.URL},
+		},
+		"h.invalid": &Rule{Selector: ".target", IDPattern: "(", Samples: []string{ts.URL}},
+	}}
+
+	results := Check(rules)
+	assert.Len(t, results, 8)
+
+	assert.Equal(t, "a.ok", results[0].Entity)
+	assert.Equal(t, CheckOK, results[0].Status)
+	// the link without the ID is matched by the selector but not counted
+	assert.Equal(t, 2, results[0].Matched)
+
+	assert.Equal(t, CheckEmpty, results[1].Status)
+	assert.Equal(t, CheckAbnormal, results[2].Status)
+
+	assert.Equal(t, CheckError, results[3].Status)
+	assert.NotNil(t, results[3].Error)
+
+	assert.Equal(t, "", results[0].EmbeddedStatus)
+
+	// the working selector is enough when embedded data is missing
+	assert.Equal(t, CheckOK, results[4].Status)
+	assert.Equal(t, CheckOK, results[4].SelectorStatus)
+	assert.Equal(t, CheckEmpty, results[4].EmbeddedStatus)
+	assert.Equal(t, 2, results[4].Matched)
+	assert.Equal(t, 0, results[4].Embedded)
+
+	// and the embedded data is enough when the selector is broken
+	assert.Equal(t, CheckOK, results[5].Status)
+	assert.Equal(t, CheckEmpty, results[5].SelectorStatus)
+	assert.Equal(t, CheckOK, results[5].EmbeddedStatus)
+	assert.Equal(t, 1, results[5].Embedded)
+
+	assert.Equal(t, CheckEmpty, results[6].Status)
+	assert.Equal(t, CheckEmpty, results[6].SelectorStatus)
+	assert.Equal(t, CheckEmpty, results[6].EmbeddedStatus)
+
+	assert.Equal(t, CheckError, results[7].Status)
+	assert.Contains(t, results[7].Error.Error(), "Invalid ID pattern")
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the latest supported version of the rules file
+const Version = 1
+
+// Rules represents set of extraction rules per entity type
+type Rules struct {
+	Version  int              `json:"version"`
+	Entities map[string]*Rule `json:"entities"`
+}
+
+// Rule represents how entities of the single type are extracted from the page
+type Rule struct {
+	Selector  string   `json:"selector"`
+	Attribute string   `json:"attribute"`
+	IDPattern string   `json:"id_pattern"`
+	Script    string   `json:"script"`
+	Match     string   `json:"match"`
+	Samples   []string `json:"samples"`
+	Min       int      `json:"min"`
+	Max       int      `json:"max"`
+
+	// patterns are compiled once when the rules are loaded
+	idPattern *regexp.Regexp
+	match     *regexp.Regexp
+}
+
+// Default returns rules compiled into the binary
+func Default() *Rules {
+
+	rules := &Rules{
+		Version: Version,
+		Entities: map[string]*Rule{
+			"genre": &Rule{
+				Selector:  ".top-level-genre, .top-level-subgenres a[href]",
+				Attribute: "href",
+				Script:    crawler.EmbeddedScript,
+				Match:     `/genre/podcasts-[^/]+/id\d+`,
+				Samples:   []string{"https://podcasts.apple.com/ua/genre/podcasts/id26"},
+				Min:       10,
+				Max:       500,
+			},
+			"show": &Rule{
+				Selector:  "div[id=selectedcontent] .column a[href]",
+				Attribute: "href",
+				Script:    crawler.EmbeddedScript,
+				Match:     `/podcast/[^/]+/id\d+`,
+				Samples:   []string{"https://podcasts.apple.com/ua/genre/podcasts-arts/id1301"},
+				Min:       10,
+				Max:       1000,
+			},
+		},
+	}
+	for name, rule := range rules.Entities {
+		if err := rule.compile(); err != nil {
+			panic(errors.Wrapf(err, "Default rule of %s is invalid", name))
+		}
+	}
+	return rules
+}
+
+// Load reads rules file and applies it on top of the default rules
+func Load(path string) (*Rules, error) {
+
+	custom := &Rules{}
+	err := static.Load(path, func(body []byte) error {
+
+		return json.Unmarshal(body, custom)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot load rules")
+	}
+
+	if custom.Version > Version {
+		return nil, errors.Errorf("Rules version %d is not supported, latest is %d", custom.Version, Version)
+	}
+
+	rules := Default()
+	for name, rule := range custom.Entities {
+		base, ok := rules.Entities[name]
+		if !ok {
+			return nil, errors.Errorf("Rules entity %q is not supported, supported are %s", name, strings.Join(rules.names(), ", "))
+		}
+		merged := base.merge(rule)
+		if err := merged.compile(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid rule of %s", name)
+		}
+		rules.Entities[name] = merged
+	}
+
+	return rules, nil
+}
+
+// Get returns rule of the entity type
+func (r *Rules) Get(name string) *Rule {
+
+	if rule, ok := r.Entities[name]; ok {
+		return rule
+	}
+	return &Rule{}
+}
+
+// names returns sorted entity names of the rules
+func (r *Rules) names() []string {
+
+	names := make([]string, 0, len(r.Entities))
+	for name := range r.Entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetScraperOptions builds crawler options for the rule
+func (r *Rule) GetScraperOptions(url []string) *crawler.ScraperOptions {
+
+	opt := crawler.GetScraperOptions(url, r.Selector)
+	opt.Attribute = r.Attribute
+	opt.IDPattern = r.idPattern
+	opt.Script = r.Script
+	opt.Match = r.match
+	return opt
+}
+
+// compile validates the rule and compiles its patterns, the ID pattern must have the group of the ID
+func (r *Rule) compile() error {
+
+	if r.Selector == "" {
+		return errors.New("Selector is empty")
+	}
+
+	r.idPattern, r.match = nil, nil
+	if r.IDPattern != "" {
+		re, err := regexp.Compile(r.IDPattern)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid ID pattern: %s", r.IDPattern)
+		}
+		if re.NumSubexp() < 1 {
+			return errors.Errorf("ID pattern has no group of the ID: %s", r.IDPattern)
+		}
+		r.idPattern = re
+	}
+	if r.Match != "" {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid embedded data pattern: %s", r.Match)
+		}
+		r.match = re
+	}
+	return nil
+}
+
+// parses reports whether the ID of the entity is parsed from the URL
+func (r *Rule) parses(url string) bool {
+
+	if url == "" {
+		return false
+	}
+	_, err := crawler.GetEntityIDByPattern(url, r.idPattern)
+	return err == nil
+}
+
+func (r *Rule) merge(src *Rule) *Rule {
+
+	res := *r
+	if src.Selector != "" {
+		res.Selector = src.Selector
+	}
+	if src.Attribute != "" {
+		res.Attribute = src.Attribute
+	}
+	if src.IDPattern != "" {
+		res.IDPattern = src.IDPattern
+	}
+	if src.Script != "" {
+		res.Script = src.Script
+	}
+	if src.Match != "" {
+		res.Match = src.Match
+	}
+	if len(src.Samples) > 0 {
+		res.Samples = src.Samples
+	}
+	if src.Min > 0 {
+		res.Min = src.Min
+	}
+	if src.Max > 0 {
+		res.Max = src.Max
+	}
+	return &res
+}
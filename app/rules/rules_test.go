@@ -0,0 +1,83 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+
+	rules := Default()
+	assert.Equal(t, Version, rules.Version)
+	for _, name := range []string{"genre", "show"} {
+		rule := rules.Get(name)
+		assert.NotEmpty(t, rule.Selector)
+		assert.NotEmpty(t, rule.Samples)
+	}
+	assert.Equal(t, &Rule{}, rules.Get("unknown"))
+}
+
+func TestLoad(t *testing.T) {
+
+	path := "/tmp/rules.test.json"
+
+	_, err := Load("/get/invalid/path")
+	assert.NotNil(t, err)
+
+	ioutil.WriteFile(path, []byte(`{
+		"version": 1,
+		"entities": {
+			"show": {"selector": ".show a", "id_pattern": "show-(\\d+)", "max": 5}
+		}
+	}`), 0644)
+	defer os.Remove(path)
+
+	rules, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, Default().Get("genre"), rules.Get("genre"))
+
+	show := rules.Get("show")
+	assert.Equal(t, ".show a", show.Selector)
+	assert.Equal(t, `show-(\d+)`, show.IDPattern)
+	assert.Equal(t, 5, show.Max)
+	assert.Equal(t, Default().Get("show").Samples, show.Samples)
+	id, err := crawler.GetEntityIDByPattern("http://x.com/show-42", show.GetScraperOptions(nil).IDPattern)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, id)
+
+	ioutil.WriteFile(path, []byte(`{"version": 99}`), 0644)
+	_, err = Load(path)
+	assert.Equal(t, "Rules version 99 is not supported, latest is 1", err.Error())
+
+	// unknown entities and invalid patterns are rejected when the rules are loaded
+	for body, msg := range map[string]string{
+		`{"entities": {"episode": {"selector": ".episode a"}}}`: `Rules entity "episode" is not supported, supported are genre, show`,
+		`{"entities": {"show": {"id_pattern": "("}}}`:           "Invalid rule of show: Invalid ID pattern: (",
+		`{"entities": {"show": {"id_pattern": "show-\\d+"}}}`:   `Invalid rule of show: ID pattern has no group of the ID: show-\d+`,
+		`{"entities": {"genre": {"match": "["}}}`:               "Invalid rule of genre: Invalid embedded data pattern: [",
+	} {
+		ioutil.WriteFile(path, []byte(body), 0644)
+		_, err = Load(path)
+		if assert.NotNil(t, err, body) {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+}
+
+func TestGetScraperOptions(t *testing.T) {
+
+	rule := &Rule{Selector: "a", Attribute: "data-href", IDPattern: "(\\d+)", Script: "script", Match: "x"}
+	assert.Nil(t, rule.compile())
+	opt := rule.GetScraperOptions([]string{"http://x.com"})
+	assert.Equal(t, []string{"http://x.com"}, opt.LookupURL)
+	assert.Equal(t, rule.Selector, opt.Pattern)
+	assert.Equal(t, rule.Attribute, opt.Attribute)
+	assert.Equal(t, rule.IDPattern, opt.IDPattern.String())
+	assert.Equal(t, rule.Script, opt.Script)
+	assert.Equal(t, rule.Match, opt.Match.String())
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/lock"
+	"github.com/zhikiri/itunes.podcasts/app/manifest"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// failuresKind is the manifest kind of the failures file
+const failuresKind = "failures"
+
+// stageKinds lists kinds produced by every stage
+var stageKinds = map[string][]string{
+	stageGenres:  {genre.Kind, failuresKind},
+	stageShows:   {show.Kind, failuresKind},
+	stageDetails: {show.DetailsKind, failuresKind},
+	stagePages:   {show.PagesKind, failuresKind},
+	stageFeed:    {show.FeedKind, failuresKind},
+	stageCompact: {CompactKind},
+	stageRetry:   {genre.Kind, show.Kind, show.DetailsKind, show.PagesKind, show.FeedKind, failuresKind},
+}
+
+// stageRun is the run of the single stage, produced files are recorded to the manifest when the process exits
+type stageRun struct {
+	stage   string
+	inputs  []string
+	flags   map[string]string
+	started time.Time
+	opt     *static.StoreOptions
+	out     static.Store
+	fails   *failures.File
+}
+
+// current is the running stage, it is nil when the command does not produce files
+var current *stageRun
+
+func newStageRun(fs *flag.FlagSet, stage string, inputs []string, opt *static.StoreOptions, out static.Store, fails *failures.File) *stageRun {
+
+	flags := map[string]string{}
+	fs.Visit(func(fl *flag.Flag) {
+		flags[fl.Name] = fl.Value.String()
+	})
+
+	crawler.StageStart(stage)
+	return &stageRun{
+		stage:   stage,
+		inputs:  inputs,
+		flags:   flags,
+		started: time.Now().UTC(),
+		opt:     opt,
+		out:     out,
+		fails:   fails,
+	}
+}
+
+// end emits the end of the stage with the number of loaded items and errors
+func (r *stageRun) end(loaded int, errs []error) {
+
+	crawler.StageEnd(r.stage, loaded, len(errs), time.Since(r.started))
+}
+
+// record adds files changed by the run to the manifest of the output folder
+func (r *stageRun) record() error {
+
+	m, err := manifest.Open(filepath.Join(r.opt.Dir, manifest.FileName))
+	if err != nil {
+		return err
+	}
+
+	finished := time.Now().UTC()
+	added := 0
+	for _, kind := range stageKinds[r.stage] {
+		name, err := r.getFileName(kind)
+		if err != nil {
+			return err
+		}
+
+		// files which are not written by the run are left as they are, e.g. the stage failed before saving
+		stat, err := os.Stat(m.Path(name))
+		if err != nil || stat.ModTime().Before(r.started.Truncate(time.Second)) {
+			continue
+		}
+
+		records, err := r.getRecords(kind)
+		if err != nil {
+			return err
+		}
+
+		err = m.Add(&manifest.File{
+			Name:     name,
+			Kind:     kind,
+			Stage:    r.stage,
+			Records:  records,
+			Inputs:   r.inputs,
+			Flags:    r.flags,
+			Started:  r.started,
+			Finished: finished,
+		})
+		if err != nil {
+			return err
+		}
+		added++
+	}
+	if added == 0 {
+		return nil
+	}
+	return m.Save(getGenerator())
+}
+
+// getFileName returns the name of the file which keeps the kind
+func (r *stageRun) getFileName(kind string) (string, error) {
+
+	if kind == failuresKind {
+		return failures.FileName, nil
+	}
+	return r.opt.FileName(kind)
+}
+
+// getRecords returns the number of records of the kind
+func (r *stageRun) getRecords(kind string) (int, error) {
+
+	if kind == failuresKind {
+		return r.fails.Len(), nil
+	}
+	ids, err := r.out.List(kind)
+	return len(ids), err
+}
+
+// locks are locks of output folders, they are referenced until the process exits, so they are not released by the GC
+var locks []*lock.Lock
+
+// exit records the manifest of the running stage and the status of the running pipeline, releases locks and exits with the code
+func exit(code int) {
+
+	saveStatusOnExit()
+	if run := current; run != nil {
+		current = nil
+		if err := run.record(); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Cannot write manifest: %s\n", err)
+		}
+	}
+	for _, lk := range locks {
+		lk.Release()
+	}
+	locks = nil
+	os.Exit(code)
+}
+
+// verifiedKinds are pairs of kinds where every ID of the first kind must exist in the second one
+var verifiedKinds = [][2]string{
+	{show.DetailsKind, show.Kind},
+	{show.PagesKind, show.Kind},
+	{show.FeedKind, show.DetailsKind},
+}
+
+func actionVerify(dir string) {
+	crawler.Infof("Starting verification of %s", dir)
+	m, err := manifest.Open(filepath.Join(dir, manifest.FileName))
+	stopOnError(err)
+	if len(m.Files) == 0 {
+		stopOnError(errors.Errorf("Manifest is missing in %s", dir))
+	}
+
+	errs := m.Verify()
+	crawler.Infof("Files checked %d", len(m.Files))
+
+	for _, pair := range verifiedKinds {
+		child, ok := m.Get(pair[0])
+		parent, pok := m.Get(pair[1])
+		if !ok || !pok {
+			continue
+		}
+		missing, err := getMissingIDs(m.Path(child.Name), child.Kind, m.Path(parent.Name), parent.Kind)
+		if err != nil {
+			errs = append(errs, err)
+		} else if len(missing) > 0 {
+			errs = append(errs, errors.Errorf(
+				"%d IDs of %s are missing in %s, e.g. %d", len(missing), child.Kind, parent.Kind, missing[0],
+			))
+		} else {
+			crawler.Infof("IDs of %s exist in %s", child.Kind, parent.Kind)
+		}
+	}
+	stopOnErrors(errs)
+}
+
+// getMissingIDs returns IDs of the child kind which are not present in the parent kind
+func getMissingIDs(childPath string, childKind string, parentPath string, parentKind string) ([]int, error) {
+	parent, err := listFileIDs(parentPath, parentKind)
+	if err != nil {
+		return nil, err
+	}
+	child, err := listFileIDs(childPath, childKind)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[int]int, len(parent))
+	for _, id := range parent {
+		exists[id] = 1
+	}
+	missing := []int{}
+	for _, id := range child {
+		if _, ok := exists[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func listFileIDs(path string, kind string) ([]int, error) {
+	store, err := static.OpenFileReader(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot open %s", path)
+	}
+	defer store.Close()
+
+	ids, err := store.List(kind)
+	return ids, errors.Wrapf(err, "Cannot read %s", path)
+}
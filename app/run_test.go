@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/failures"
+	"github.com/zhikiri/itunes.podcasts/app/manifest"
+	"github.com/zhikiri/itunes.podcasts/app/show"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageRunRecord(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "run.test")
+	defer os.RemoveAll(dir)
+
+	opt := &static.StoreOptions{Format: "jsonl", Dir: dir}
+	out, _ := static.OpenStore(opt)
+	fails, _ := failures.Open(filepath.Join(dir, failures.FileName))
+
+	run := newStageRun(flag.NewFlagSet("details", flag.ContinueOnError), stageDetails, []string{"/tmp/shows.json"}, opt, out, fails)
+	assert.Nil(t, show.SaveDetails(out, []*show.ShowDetails{{ID: 1}, {ID: 2}}))
+	assert.Nil(t, out.Close())
+	assert.Nil(t, run.record())
+
+	m, err := manifest.Open(filepath.Join(dir, manifest.FileName))
+	assert.Nil(t, err)
+	// the failures file is not written by the run
+	assert.Len(t, m.Files, 1)
+
+	details, ok := m.Get(show.DetailsKind)
+	assert.True(t, ok)
+	assert.Equal(t, "shows.details.jsonl", details.Name)
+	assert.Equal(t, stageDetails, details.Stage)
+	assert.Equal(t, 2, details.Records)
+	assert.Equal(t, []string{"/tmp/shows.json"}, details.Inputs)
+	assert.Len(t, details.SHA256, 64)
+	assert.False(t, details.Finished.Before(details.Started))
+	assert.Len(t, m.Verify(), 0)
+}
+
+func TestGetMissingIDs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "run.test")
+	defer os.RemoveAll(dir)
+
+	out := static.NewJSONLStore(dir)
+	show.Save(out, []*show.Show{{ID: 1}, {ID: 2}})
+	show.SaveDetails(out, []*show.ShowDetails{{ID: 1}, {ID: 3}, {ID: 4}})
+	out.Close()
+
+	missing, err := getMissingIDs(
+		filepath.Join(dir, "shows.details.jsonl"), show.DetailsKind,
+		filepath.Join(dir, "shows.jsonl"), show.Kind,
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 4}, missing)
+
+	_, err = getMissingIDs(
+		filepath.Join(dir, "shows.details.jsonl"), show.DetailsKind,
+		filepath.Join(dir, "shows.json"), show.Kind,
+	)
+	assert.NotNil(t, err)
+}
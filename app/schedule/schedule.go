@@ -0,0 +1,253 @@
+package schedule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/pkg/errors"
+)
+
+// FileName is the name of the schedule state file in the output folder
+const FileName = "daemon.state.json"
+
+// states of jobs
+const (
+	StateIdle    = "idle"
+	StateRunning = "running"
+	StateDone    = "done"
+	StatePartial = "partial"
+	StateFailed  = "failed"
+)
+
+// RetryDelay is the delay before the failed job runs again, unless its interval is shorter
+var RetryDelay = 10 * time.Minute
+
+// Job runs the part of the pipeline every interval, the interval is counted from the finish of the previous run
+type Job struct {
+	Name     string
+	Interval time.Duration
+}
+
+// Runner runs the job and returns the exit code of the run: 0 is done, 3 is partial and others are failures
+type Runner func(job *Job) (int, error)
+
+// JobStatus is the persisted state of the job
+type JobStatus struct {
+	Name     string     `json:"name"`
+	Every    string     `json:"every"`
+	State    string     `json:"state"`
+	Runs     int        `json:"runs"`
+	Failures int        `json:"failures"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Code     int        `json:"code"`
+	Error    string     `json:"error,omitempty"`
+	Next     time.Time  `json:"next"`
+}
+
+// Status is the state of the scheduler, it is saved after every change and served over HTTP
+type Status struct {
+	Started time.Time    `json:"started"`
+	Updated time.Time    `json:"updated"`
+	Running string       `json:"running,omitempty"`
+	Jobs    []*JobStatus `json:"jobs"`
+}
+
+// Scheduler runs jobs one at a time in the order they are due, so runs never overlap
+type Scheduler struct {
+	mu     sync.Mutex
+	path   string
+	jobs   []*Job
+	status *Status
+	now    func() time.Time
+}
+
+// New creates the scheduler with the state of the file, jobs which never finished are due immediately.
+// The job interrupted in the middle of the run is marked failed.
+func New(path string, jobs []*Job) (*Scheduler, error) {
+
+	return newScheduler(path, jobs, func() time.Time { return time.Now().UTC() })
+}
+
+func newScheduler(path string, jobs []*Job, now func() time.Time) (*Scheduler, error) {
+
+	saved := &Status{}
+	body, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "Cannot read schedule state")
+	}
+	if err == nil {
+		if err = json.Unmarshal(body, saved); err != nil {
+			return nil, errors.Wrapf(err, "Schedule state %s is broken", path)
+		}
+	}
+
+	known := map[string]*JobStatus{}
+	for _, st := range saved.Jobs {
+		known[st.Name] = st
+	}
+
+	s := &Scheduler{path: path, jobs: jobs, now: now, status: &Status{Started: now()}}
+	for _, job := range jobs {
+		st, ok := known[job.Name]
+		if !ok {
+			st = &JobStatus{Name: job.Name, State: StateIdle}
+		}
+		if st.State == StateRunning {
+			st.State = StateFailed
+			st.Failures++
+			st.Error = "Run is interrupted"
+		}
+		st.Every = job.Interval.String()
+		st.Next = s.getNext(job, st)
+		s.status.Jobs = append(s.status.Jobs, st)
+	}
+	return s, nil
+}
+
+// getNext returns the time of the next run, the changed interval applies to the finished run too
+func (s *Scheduler) getNext(job *Job, st *JobStatus) time.Time {
+
+	if st.Finished == nil {
+		return s.now()
+	}
+	if st.State == StateFailed && RetryDelay < job.Interval {
+		return st.Finished.Add(RetryDelay)
+	}
+	return st.Finished.Add(job.Interval)
+}
+
+// Next returns the job which is due first and its time, jobs due at the same time go in the order of the list
+func (s *Scheduler) Next() (*Job, time.Time) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *Job
+	var at time.Time
+	for i, job := range s.jobs {
+		st := s.status.Jobs[i]
+		if next == nil || st.Next.Before(at) {
+			next, at = job, st.Next
+		}
+	}
+	return next, at
+}
+
+// Run runs the job and records the result, the state is saved before and after the run
+func (s *Scheduler) Run(job *Job, run Runner) error {
+
+	st := s.get(job.Name)
+	s.mu.Lock()
+	started := s.now()
+	st.State = StateRunning
+	st.Started = &started
+	s.status.Running = job.Name
+	s.mu.Unlock()
+	if err := s.Save(); err != nil {
+		return err
+	}
+
+	code, err := run(job)
+
+	s.mu.Lock()
+	finished := s.now()
+	st.Finished = &finished
+	st.Runs++
+	st.Code = code
+	st.Error = ""
+	switch {
+	case err != nil:
+		st.State = StateFailed
+		st.Error = err.Error()
+	case code == 0:
+		st.State = StateDone
+	case code == 3:
+		st.State = StatePartial
+	default:
+		st.State = StateFailed
+	}
+	if st.State == StateFailed {
+		st.Failures++
+	}
+	st.Next = s.getNext(job, st)
+	s.status.Running = ""
+	s.mu.Unlock()
+	return s.Save()
+}
+
+// Loop runs jobs when they are due until done is closed, the running job is finished before the loop returns
+func (s *Scheduler) Loop(run Runner, done <-chan struct{}) error {
+
+	for {
+		job, at := s.Next()
+		if job == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(at.Sub(s.now()))
+		select {
+		case <-done:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if err := s.Run(job, run); err != nil {
+			return err
+		}
+	}
+}
+
+// Status returns the copy of the scheduler state
+func (s *Scheduler) Status() *Status {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := *s.status
+	res.Jobs = make([]*JobStatus, 0, len(s.status.Jobs))
+	for _, st := range s.status.Jobs {
+		job := *st
+		res.Jobs = append(res.Jobs, &job)
+	}
+	return &res
+}
+
+// Save writes the state to the file
+func (s *Scheduler) Save() error {
+
+	st := s.Status()
+	st.Updated = s.now()
+	return static.Save(s.path, func() ([]byte, error) {
+		return json.MarshalIndent(st, "", "  ")
+	})
+}
+
+// ServeHTTP writes the state of the scheduler as JSON
+func (s *Scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	st := s.Status()
+	st.Updated = s.now()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+func (s *Scheduler) get(name string) *JobStatus {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.status.Jobs {
+		if st.Name == name {
+			return st
+		}
+	}
+	return nil
+}
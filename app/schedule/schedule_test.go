@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) get() time.Time {
+	return c.now
+}
+
+func newTestJobs() []*Job {
+	return []*Job{
+		{Name: "genres", Interval: 7 * 24 * time.Hour},
+		{Name: "shows", Interval: 24 * time.Hour},
+		{Name: "details", Interval: 10 * time.Minute},
+		{Name: "feed", Interval: time.Hour},
+	}
+}
+
+func TestScheduler(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "schedule.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, FileName)
+
+	clock := &testClock{time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)}
+	s, err := newScheduler(path, newTestJobs(), clock.get)
+	assert.Nil(t, err)
+
+	// every job is due on the first start, they go in the order of the list
+	ran := []string{}
+	runner := func(job *Job) (int, error) {
+		ran = append(ran, job.Name)
+		assert.Equal(t, job.Name, s.Status().Running)
+		clock.now = clock.now.Add(time.Minute)
+		switch job.Name {
+		case "details":
+			return 3, nil
+		case "feed":
+			return 1, nil
+		}
+		return 0, nil
+	}
+	for i := 0; i < 4; i++ {
+		job, at := s.Next()
+		assert.False(t, at.After(clock.now))
+		assert.Nil(t, s.Run(job, runner))
+	}
+	assert.Equal(t, []string{"genres", "shows", "details", "feed"}, ran)
+
+	// details are due 10 minutes after their finish
+	job, at := s.Next()
+	assert.Equal(t, "details", job.Name)
+	assert.Equal(t, time.Date(2026, 10, 19, 10, 13, 0, 0, time.UTC), at)
+
+	st := s.Status()
+	assert.Equal(t, "", st.Running)
+	assert.Equal(t, StateDone, st.Jobs[0].State)
+	assert.Equal(t, StatePartial, st.Jobs[2].State)
+	assert.Equal(t, StateFailed, st.Jobs[3].State)
+	assert.Equal(t, 1, st.Jobs[3].Failures)
+	assert.Equal(t, "1h0m0s", st.Jobs[3].Every)
+
+	// the failed job is retried sooner than its interval
+	assert.Equal(t, time.Date(2026, 10, 19, 10, 14, 0, 0, time.UTC), st.Jobs[3].Next)
+
+	// the state is restored by the next start, the changed interval applies at once
+	jobs := newTestJobs()
+	jobs[1].Interval = 12 * time.Hour
+	restored, err := newScheduler(path, jobs, clock.get)
+	assert.Nil(t, err)
+	shows := restored.Status().Jobs[1]
+	assert.Equal(t, 1, shows.Runs)
+	assert.Equal(t, time.Date(2026, 10, 19, 22, 2, 0, 0, time.UTC), shows.Next)
+}
+
+func TestSchedulerInterrupted(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "schedule.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, FileName)
+
+	clock := &testClock{time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)}
+	s, _ := newScheduler(path, newTestJobs(), clock.get)
+	job, _ := s.Next()
+
+	// the state is saved as running while the job runs
+	s.Run(job, func(job *Job) (int, error) {
+		restored, err := newScheduler(path, newTestJobs(), clock.get)
+		assert.Nil(t, err)
+		st := restored.Status().Jobs[0]
+		assert.Equal(t, StateFailed, st.State)
+		assert.Equal(t, "Run is interrupted", st.Error)
+		assert.Equal(t, clock.now, st.Next)
+		return 0, errors.New("Cannot start run")
+	})
+	assert.Equal(t, "Cannot start run", s.Status().Jobs[0].Error)
+
+	ioutil.WriteFile(path, []byte("{"), 0644)
+	_, err := newScheduler(path, newTestJobs(), clock.get)
+	assert.NotNil(t, err)
+}
+
+func TestLoop(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "schedule.test")
+	defer os.RemoveAll(dir)
+
+	s, _ := New(filepath.Join(dir, FileName), []*Job{{Name: "feed", Interval: time.Hour}})
+	done := make(chan struct{})
+	runs := 0
+	err := s.Loop(func(job *Job) (int, error) {
+		runs++
+		close(done)
+		return 0, nil
+	}, done)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, runs)
+}
+
+func TestServeHTTP(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "schedule.test")
+	defer os.RemoveAll(dir)
+
+	s, _ := New(filepath.Join(dir, FileName), newTestJobs())
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+
+	st := &Status{}
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), st))
+	assert.Len(t, st.Jobs, 4)
+	assert.Equal(t, StateIdle, st.Jobs[1].State)
+}
@@ -11,19 +11,21 @@ import (
 	"github.com/pkg/errors"
 )
 
+const DetailsKind = "shows.details"
+
 type ShowDetails struct {
-	ID     int
-	RSS    string
-	Name   string
-	Genres []string
-	Artist string
-	Image  ShowImage
+	ID     int       `json:"id"`
+	RSS    string    `json:"rss"`
+	Name   string    `json:"name"`
+	Genres []string  `json:"genres"`
+	Artist string    `json:"artist"`
+	Image  ShowImage `json:"image"`
 }
 
 type ShowImage struct {
-	Big    string
-	Small  string
-	Medium string
+	Big    string `json:"big"`
+	Small  string `json:"small"`
+	Medium string `json:"medium"`
 }
 
 type lookupResponse struct {
@@ -50,47 +52,70 @@ func GetDetailsRequestOptions(shows []*Show, delay time.Duration) *crawler.Limit
 	return &crawler.LimitedRequestOptions{
 		LookupURL: urls,
 		Duration:  delay,
+		IsEmpty:   isEmptyLookup,
 	}
 }
 
-func GetDetails(opt *crawler.LimitedRequestOptions) ([]*ShowDetails, []error) {
+// DetailsResult represents the lookup result of the single show
+type DetailsResult struct {
+	ID      int
+	URL     string
+	Details *ShowDetails
+	Error   error
+}
 
-	details := []*ShowDetails{}
-	errs := []error{}
+// StreamDetails calls fn with every lookup result as soon as it arrives
+func StreamDetails(opt *crawler.LimitedRequestOptions, fn func(*DetailsResult)) {
 
 	out := crawler.RequestEntitiesWithLimiter(opt, lookupDecoder)
 	for en := range out {
-		if en.Error != nil {
-			errs = append(errs, en.Error)
-			continue
+		res := &DetailsResult{URL: en.URL, Error: en.Error}
+		res.ID, _ = crawler.GetEntityIDFromURL(en.URL)
+		if res.Error == nil {
+			res.Details, res.Error = getLookupDetails(en.Entity, en.URL)
 		}
-		det, err := getLookupDetails(en.Entity)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-
-		details = append(details, det)
+		fn(res)
 	}
-
-	return details, errs
 }
 
-func SaveDetails(path string, details []*ShowDetails) error {
+func GetDetails(opt *crawler.LimitedRequestOptions) ([]*ShowDetails, []error) {
 
-	return static.Save(path, func() ([]byte, error) {
+	details := []*ShowDetails{}
+	errs := []error{}
 
-		return json.Marshal(details)
+	StreamDetails(opt, func(res *DetailsResult) {
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			return
+		}
+		details = append(details, res.Details)
 	})
+
+	return details, errs
 }
 
-func GetShowDetailsFromFile(path string) ([]*ShowDetails, error) {
+func SaveDetails(store static.Store, details []*ShowDetails) error {
+
+	for _, det := range details {
+		if err := store.Put(DetailsKind, det.ID, det); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
+}
+
+func LoadDetails(store static.Store) ([]*ShowDetails, error) {
 
 	details := []*ShowDetails{}
 
-	err := static.Load(path, func(body []byte) error {
+	err := store.Iterate(DetailsKind, func(id int, decode static.EntityDecoder) error {
 
-		return json.Unmarshal(body, &details)
+		det := &ShowDetails{}
+		if err := decode(det); err != nil {
+			return err
+		}
+		details = append(details, det)
+		return nil
 	})
 
 	if err != nil {
@@ -100,6 +125,24 @@ func GetShowDetailsFromFile(path string) ([]*ShowDetails, error) {
 	return details, nil
 }
 
+func GetShowDetailsFromFile(path string) ([]*ShowDetails, error) {
+
+	store, err := static.OpenFile(path)
+	if err != nil {
+		return []*ShowDetails{}, err
+	}
+	defer store.Close()
+
+	return LoadDetails(store)
+}
+
+// isEmptyLookup checks the lookup without results, several ones in a row usually mean that the API throttles requests
+func isEmptyLookup(entity interface{}) bool {
+
+	res, ok := entity.(lookupResponse)
+	return ok && len(res.Results) == 0
+}
+
 func lookupDecoder(url string, body []byte) (interface{}, error) {
 
 	var res lookupResponse
@@ -111,7 +154,7 @@ func lookupDecoder(url string, body []byte) (interface{}, error) {
 	return res, err
 }
 
-func getLookupDetails(entity interface{}) (*ShowDetails, error) {
+func getLookupDetails(entity interface{}, url string) (*ShowDetails, error) {
 
 	res, ok := entity.(lookupResponse)
 	if !ok {
@@ -119,7 +162,7 @@ func getLookupDetails(entity interface{}) (*ShowDetails, error) {
 	}
 
 	if len(res.Results) == 0 {
-		return &ShowDetails{}, errors.New("Show is not found")
+		return &ShowDetails{}, &crawler.NotFoundError{URL: url}
 	}
 
 	apiRes := res.Results[0]
@@ -1,9 +1,7 @@
 package show
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -12,6 +10,7 @@ import (
 	"time"
 
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/static"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -106,8 +105,11 @@ func TestGetDetails(t *testing.T) {
 	}
 	_, errs = GetDetails(opt)
 	assert.NotEmpty(t, errs)
-	msg := fmt.Sprintf("Unreachable URL: %s/404", ts.URL)
-	assert.Equal(t, msg, errors.Cause(errs[0]).Error())
+	var status *crawler.HTTPStatusError
+	assert.True(t, errors.As(errs[0], &status))
+	assert.Equal(t, 404, status.Code)
+	msg := fmt.Sprintf("Unreachable URL: %s/404 (status 404)", ts.URL)
+	assert.Equal(t, msg, errs[0].Error())
 
 	opt = &crawler.LimitedRequestOptions{
 		LookupURL: []string{ts.URL + "/invalid"},
@@ -115,7 +117,9 @@ func TestGetDetails(t *testing.T) {
 	}
 	_, errs = GetDetails(opt)
 	assert.NotEmpty(t, errs)
-	assert.Equal(t, "Show is not found", errors.Cause(errs[0]).Error())
+	var notFound *crawler.NotFoundError
+	assert.True(t, errors.As(errs[0], &notFound))
+	assert.Equal(t, ts.URL+"/invalid", notFound.URL)
 }
 
 func TestGetDetailsRequestOptions(t *testing.T) {
@@ -132,6 +136,10 @@ func TestGetDetailsRequestOptions(t *testing.T) {
 		assert.Contains(t, opt.LookupURL, url)
 	}
 	assert.Equal(t, time.Second*5, opt.Duration)
+
+	// lookups without results slow requests down
+	assert.True(t, opt.IsEmpty(lookupResponse{}))
+	assert.False(t, opt.IsEmpty(&lookupResponse{}))
 }
 
 func TestGetShowDetailsFromFile(t *testing.T) {
@@ -147,8 +155,9 @@ func TestGetShowDetailsFromFile(t *testing.T) {
 		for i := 1; i <= 5; i++ {
 			det = append(det, &ShowDetails{ID: i})
 		}
-		json, _ := json.Marshal(det)
-		ioutil.WriteFile(path, json, 0644)
+		store, _ := static.OpenFile(path)
+		SaveDetails(store, det)
+		store.Close()
 	}()
 
 	det, err = GetShowDetailsFromFile(path)
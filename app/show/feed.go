@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"strings"
+	"time"
 
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
 	"github.com/zhikiri/itunes.podcasts/app/static"
@@ -12,17 +13,22 @@ import (
 	"github.com/pkg/errors"
 )
 
+const FeedKind = "shows.feed"
+
+// Feed keeps the last successfully fetched feed data along with the error of the latest attempt
 type Feed struct {
-	ID          int
-	Language    string
-	Description string
-	LastPodcast Podcast
+	ID          int       `json:"id"`
+	Language    string    `json:"language"`
+	Description string    `json:"description"`
+	LastPodcast Podcast   `json:"last_podcast"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	LastError   string    `json:"last_error,omitempty"`
 }
 
 type Podcast struct {
-	Title       string
-	Published   string
-	Description string
+	Title       string `json:"title"`
+	Published   string `json:"published"`
+	Description string `json:"description"`
 }
 
 type RSS struct {
@@ -47,45 +53,123 @@ func (f *Feed) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct{ *feedAlias }{feedAlias: (*feedAlias)(f)})
 }
 
-func GetFeed(shows []*ShowDetails) ([]*Feed, []error) {
+// IsFetched checks if the feed has data, feeds saved before tracking the fetch time are considered as fetched
+func (f *Feed) IsFetched() bool {
+	return !f.FetchedAt.IsZero() || f.LastError == ""
+}
 
-	urlToID := getShowsURLToID(shows)
+// IsFresh checks if the feed was fetched successfully within the TTL
+func (f *Feed) IsFresh(ttl time.Duration, now time.Time) bool {
+	return f.LastError == "" && !f.FetchedAt.IsZero() && now.Sub(f.FetchedAt) < ttl
+}
 
-	feedList := make([]*Feed, 0, len(shows))
-	errs := []error{}
+// FeedResult represents the feed loading result of the single show
+type FeedResult struct {
+	ID    int
+	URL   string
+	Feed  *Feed
+	Error error
+}
+
+// StreamFeed calls fn with every feed as soon as it is loaded
+func StreamFeed(shows []*ShowDetails, fn func(*FeedResult)) {
+
+	urlToID := getShowsURLToID(shows)
 
 	out := crawler.RequestEntities(getRequestOptions(shows), rssDecoder)
 	for entity := range out {
-		if entity.Error != nil {
-			errs = append(errs, entity.Error)
-			continue
+		res := &FeedResult{ID: urlToID[entity.URL], URL: entity.URL, Error: entity.Error}
+		if res.Error == nil {
+			res.Feed, res.Error = getFeedData(entity.Entity, entity.URL, urlToID)
 		}
-		feed, err := getFeedData(entity.Entity, entity.URL, urlToID)
-		if err != nil {
-			errs = append(errs, err)
-		} else {
-			feedList = append(feedList, feed)
+		fn(res)
+	}
+}
+
+// GetShowsWithFeed returns shows which have the feed URL and the number of shows without it
+func GetShowsWithFeed(shows []*ShowDetails) ([]*ShowDetails, int) {
+
+	res := make([]*ShowDetails, 0, len(shows))
+	for _, details := range shows {
+		if details.RSS != "" {
+			res = append(res, details)
 		}
 	}
+	return res, len(shows) - len(res)
+}
 
-	return feedList, errs
+// GetStaleShows returns shows without the feed or with the feed which is not fresh
+func GetStaleShows(shows []*ShowDetails, cached map[int]*Feed, ttl time.Duration, now time.Time) []*ShowDetails {
+
+	res := []*ShowDetails{}
+	for _, details := range shows {
+		if feed, ok := cached[details.ID]; ok && feed.IsFresh(ttl, now) {
+			continue
+		}
+		res = append(res, details)
+	}
+	return res
+}
+
+// MergeFeed returns the feed to save, on failure the last good data of the cached feed is kept
+func MergeFeed(cached *Feed, res *FeedResult, now time.Time) *Feed {
+
+	if res.Error == nil {
+		feed := *res.Feed
+		feed.FetchedAt = now
+		feed.LastError = ""
+		return &feed
+	}
+
+	feed := Feed{ID: res.ID}
+	if cached != nil {
+		feed = *cached
+	}
+	feed.LastError = res.Error.Error()
+	return &feed
 }
 
-func SaveFeed(path string, feed []*Feed) error {
+func GetFeed(shows []*ShowDetails) ([]*Feed, []error) {
 
-	return static.Save(path, func() ([]byte, error) {
+	feedList := make([]*Feed, 0, len(shows))
+	errs := []error{}
 
-		return json.Marshal(feed)
+	StreamFeed(shows, func(res *FeedResult) {
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+		} else {
+			feedList = append(feedList, res.Feed)
+		}
 	})
+
+	return feedList, errs
 }
 
-func GetShowFeedsFromFile(path string) ([]*Feed, error) {
+func SaveFeed(store static.Store, feed []*Feed) error {
+
+	if err := store.Clear(FeedKind); err != nil {
+		return err
+	}
+	for _, feed := range feed {
+		if err := store.Put(FeedKind, feed.ID, feed); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
+}
+
+func LoadFeeds(store static.Store) ([]*Feed, error) {
 
 	feeds := []*Feed{}
 
-	err := static.Load(path, func(body []byte) error {
+	err := store.Iterate(FeedKind, func(id int, decode static.EntityDecoder) error {
 
-		return json.Unmarshal(body, &feeds)
+		feed := &Feed{}
+		if err := decode(feed); err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+		return nil
 	})
 
 	if err != nil {
@@ -95,6 +179,17 @@ func GetShowFeedsFromFile(path string) ([]*Feed, error) {
 	return feeds, nil
 }
 
+func GetShowFeedsFromFile(path string) ([]*Feed, error) {
+
+	store, err := static.OpenFile(path)
+	if err != nil {
+		return []*Feed{}, err
+	}
+	defer store.Close()
+
+	return LoadFeeds(store)
+}
+
 func getShowsURLToID(shows []*ShowDetails) map[string]int {
 
 	res := map[string]int{}
@@ -6,6 +6,9 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -95,14 +98,55 @@ func TestGetFeed(t *testing.T) {
 	}
 	_, errs = GetFeed(details)
 	assert.NotEmpty(t, errs)
-	msg := fmt.Sprintf("Unreachable URL: %s/404", ts.URL)
-	assert.Equal(t, msg, errors.Cause(errs[0]).Error())
+	var notFound *crawler.NotFoundError
+	assert.True(t, errors.As(errs[0], &notFound))
+	assert.Equal(t, crawler.ErrorClassNotFound, crawler.GetErrorClass(errs[0]))
 
 	details = []*ShowDetails{
 		&ShowDetails{ID: 1, RSS: ts.URL + "/invalid"},
 	}
 	_, errs = GetFeed(details)
 	assert.NotEmpty(t, errs)
-	msg = "expected element type <rss> but have <invalid>"
-	assert.Equal(t, msg, errors.Cause(errs[0]).Error())
+	var decode *crawler.DecodeError
+	assert.True(t, errors.As(errs[0], &decode))
+	assert.Equal(t, "expected element type <rss> but have <invalid>", decode.Err.Error())
+}
+
+func TestGetShowsWithFeed(t *testing.T) {
+
+	details := []*ShowDetails{&ShowDetails{ID: 1, RSS: "http://x.com/1.rss"}, &ShowDetails{ID: 2}}
+	res, skipped := GetShowsWithFeed(details)
+	assert.Equal(t, []*ShowDetails{details[0]}, res)
+	assert.Equal(t, 1, skipped)
+}
+
+func TestGetStaleShows(t *testing.T) {
+
+	now := time.Now()
+	details := []*ShowDetails{&ShowDetails{ID: 1}, &ShowDetails{ID: 2}, &ShowDetails{ID: 3}, &ShowDetails{ID: 4}}
+	cached := map[int]*Feed{
+		1: &Feed{ID: 1, FetchedAt: now.Add(-time.Hour)},
+		2: &Feed{ID: 2, FetchedAt: now.Add(-48 * time.Hour)},
+		3: &Feed{ID: 3, FetchedAt: now.Add(-time.Hour), LastError: "Failed"},
+	}
+
+	stale := GetStaleShows(details, cached, 24*time.Hour, now)
+	assert.Equal(t, []*ShowDetails{details[1], details[2], details[3]}, stale)
+}
+
+func TestMergeFeed(t *testing.T) {
+
+	now := time.Now()
+	cached := &Feed{ID: 1, Language: "en", FetchedAt: now.Add(-time.Hour)}
+
+	feed := MergeFeed(cached, &FeedResult{ID: 1, Feed: &Feed{ID: 1, Language: "uk"}}, now)
+	assert.Equal(t, &Feed{ID: 1, Language: "uk", FetchedAt: now}, feed)
+
+	feed = MergeFeed(cached, &FeedResult{ID: 1, Error: errors.New("Failed")}, now)
+	assert.Equal(t, &Feed{ID: 1, Language: "en", FetchedAt: cached.FetchedAt, LastError: "Failed"}, feed)
+	assert.True(t, feed.IsFetched())
+
+	feed = MergeFeed(nil, &FeedResult{ID: 2, Error: errors.New("Failed")}, now)
+	assert.Equal(t, &Feed{ID: 2, LastError: "Failed"}, feed)
+	assert.False(t, feed.IsFetched())
 }
@@ -0,0 +1,78 @@
+package show
+
+import "github.com/zhikiri/itunes.podcasts/app/genre"
+
+// Filter selects shows by IDs and genres, the nil filter selects every show
+type Filter struct {
+	Genres *genre.Selector
+	// Include is the allowlist of show IDs, every show is allowed when it is empty
+	Include map[int]bool
+	// Exclude is the blocklist of show IDs
+	Exclude map[int]bool
+}
+
+func NewFilter(genres *genre.Selector, include []int, exclude []int) *Filter {
+
+	res := &Filter{Genres: genres, Include: map[int]bool{}, Exclude: map[int]bool{}}
+	for _, id := range include {
+		res.Include[id] = true
+	}
+	for _, id := range exclude {
+		res.Exclude[id] = true
+	}
+	return res
+}
+
+// IsEmpty checks that the filter selects every show
+func (f *Filter) IsEmpty() bool {
+
+	return f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0 && f.Genres.IsEmpty())
+}
+
+// AllowsID checks the show ID against the allowlist and the blocklist
+func (f *Filter) AllowsID(id int) bool {
+
+	if f == nil {
+		return true
+	}
+	return !f.Exclude[id] && (len(f.Include) == 0 || f.Include[id])
+}
+
+// AllowsDetails checks the show ID and genres of the details, shows without details are not allowed by genre filters
+func (f *Filter) AllowsDetails(id int, details *ShowDetails) bool {
+
+	if f.IsEmpty() {
+		return true
+	}
+	if !f.AllowsID(id) {
+		return false
+	}
+	if details == nil {
+		return f.Genres.IsEmpty()
+	}
+	return f.Genres.Allows(details.Genres)
+}
+
+// Shows returns shows allowed by IDs
+func (f *Filter) Shows(shows []*Show) []*Show {
+
+	res := make([]*Show, 0, len(shows))
+	for _, show := range shows {
+		if f.AllowsID(show.ID) {
+			res = append(res, show)
+		}
+	}
+	return res
+}
+
+// Details returns details allowed by IDs and genres
+func (f *Filter) Details(details []*ShowDetails) []*ShowDetails {
+
+	res := make([]*ShowDetails, 0, len(details))
+	for _, det := range details {
+		if f.AllowsDetails(det.ID, det) {
+			res = append(res, det)
+		}
+	}
+	return res
+}
@@ -0,0 +1,33 @@
+package show
+
+import (
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/genre"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+
+	var empty *Filter
+	assert.True(t, empty.IsEmpty())
+	assert.True(t, empty.AllowsID(1))
+	assert.True(t, empty.AllowsDetails(1, nil))
+	assert.True(t, empty.AllowsDetails(1, &ShowDetails{Genres: []string{"1"}}))
+
+	shows := []*Show{{ID: 1}, {ID: 2}, {ID: 3}}
+	assert.Equal(t, shows[1:2], NewFilter(nil, []int{2, 3}, []int{3}).Shows(shows))
+	assert.Equal(t, shows[:2], NewFilter(nil, nil, []int{3}).Shows(shows))
+	assert.True(t, NewFilter(nil, nil, []int{3}).AllowsDetails(1, nil))
+
+	sel, _ := genre.NewSelector(nil, nil, []string{"1305"})
+	flt := NewFilter(sel, nil, []int{3})
+	details := []*ShowDetails{
+		{ID: 1, Genres: []string{"1301", "26"}},
+		{ID: 2, Genres: []string{"1305", "26"}},
+		{ID: 3, Genres: []string{"1301", "26"}},
+	}
+	assert.Equal(t, details[:1], flt.Details(details))
+	assert.False(t, flt.AllowsDetails(1, nil))
+}
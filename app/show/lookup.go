@@ -0,0 +1,64 @@
+package show
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+
+	"github.com/pkg/errors"
+)
+
+// GetShowRefs splits references into shows (IDs or Apple URLs) and feed URLs
+func GetShowRefs(refs []string) ([]*Show, []string, []error) {
+
+	shows := []*Show{}
+	feeds := []string{}
+	errs := []error{}
+
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		if id, err := strconv.Atoi(ref); err == nil {
+			shows = append(shows, NewShow(id, "", ""))
+			continue
+		}
+
+		if !isAppleURL(ref) {
+			if parsed, err := url.Parse(ref); err == nil && parsed.Host != "" {
+				feeds = append(feeds, ref)
+			} else {
+				errs = append(errs, errors.Errorf("Unsupported reference: %s", ref))
+			}
+			continue
+		}
+
+		parsed, err := crawler.ParseURL(ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		shows = append(shows, NewShow(parsed.ID, ref, parsed.Slug))
+	}
+
+	return shows, feeds, errs
+}
+
+func isAppleURL(ref string) bool {
+
+	src := ref
+	if !strings.Contains(src, "://") {
+		src = "https://" + src
+	}
+
+	parsed, err := url.Parse(src)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return host == "apple.com" || strings.HasSuffix(host, ".apple.com")
+}
@@ -0,0 +1,29 @@
+package show
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetShowRefs(t *testing.T) {
+
+	shows, feeds, errs := GetShowRefs([]string{
+		"123",
+		" ",
+		"https://podcasts.apple.com/us/podcast/the-daily/id1200361736?i=1000456",
+		"itunes.apple.com/podcast/legacy/id42?uo=4",
+		"https://feeds.x.com/rss",
+		"https://podcasts.apple.com/us/podcast/no-id",
+		"not a reference",
+	})
+
+	assert.Equal(t, []*Show{
+		NewShow(123, "", ""),
+		NewShow(1200361736, "https://podcasts.apple.com/us/podcast/the-daily/id1200361736?i=1000456", "the-daily"),
+		NewShow(42, "itunes.apple.com/podcast/legacy/id42?uo=4", "legacy"),
+	}, shows)
+	assert.Equal(t, []string{"https://feeds.x.com/rss"}, feeds)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "Unsupported reference: not a reference", errs[1].Error())
+}
@@ -0,0 +1,180 @@
+package show
+
+import (
+	"time"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+)
+
+const PagesKind = "shows.pages"
+
+type ShowPage struct {
+	ID               int        `json:"id"`
+	URL              string     `json:"url"`
+	Description      string     `json:"description"`
+	Provider         string     `json:"provider"`
+	Website          string     `json:"website"`
+	MoreFromProvider []*Show    `json:"more_from_provider"`
+	AlsoSubscribed   []*Show    `json:"also_subscribed"`
+	Episodes         []*Episode `json:"episodes"`
+}
+
+type Episode struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func GetPagesRequestOptions(shows []*Show, delay time.Duration) *crawler.PageScraperOptions {
+
+	urls := []string{}
+	for _, show := range shows {
+		urls = append(urls, show.URL)
+	}
+
+	return crawler.GetPageScraperOptions(
+		urls,
+		map[string]string{
+			"description":      ".product-hero-desc__section p",
+			"provider":         ".product-header__identity a",
+			"website":          ".product-hero__links a.link[href]",
+			"moreFromProvider": "section:has(h2:contains(\"More by\")) a.we-lockup__link[href]",
+			"alsoSubscribed":   "section:has(h2:contains(\"Listeners Also Subscribed\")) a.we-lockup__link[href]",
+			"episodes":         ".tracks__track a.tracks__track__link[href]",
+		},
+		delay,
+	)
+}
+
+// PageResult represents the scraping result of the single show page
+type PageResult struct {
+	ID    int
+	URL   string
+	Page  *ShowPage
+	Error error
+}
+
+// StreamPages calls fn with every show page as soon as it is scraped
+func StreamPages(opt *crawler.PageScraperOptions, fn func(*PageResult)) {
+
+	for scraped := range crawler.ScrapePages(opt) {
+		res := &PageResult{URL: scraped.URL, Error: scraped.Error}
+		res.ID, _ = crawler.GetEntityIDFromURL(scraped.URL)
+		if res.Error == nil {
+			res.Page, res.Error = getShowPage(scraped)
+		}
+		fn(res)
+	}
+}
+
+func GetPages(opt *crawler.PageScraperOptions) ([]*ShowPage, []error) {
+
+	pages := []*ShowPage{}
+	errs := []error{}
+
+	StreamPages(opt, func(res *PageResult) {
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			return
+		}
+		pages = append(pages, res.Page)
+	})
+
+	return pages, errs
+}
+
+func SavePages(store static.Store, pages []*ShowPage) error {
+
+	for _, page := range pages {
+		if err := store.Put(PagesKind, page.ID, page); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
+}
+
+func LoadPages(store static.Store) ([]*ShowPage, error) {
+
+	pages := []*ShowPage{}
+
+	err := store.Iterate(PagesKind, func(id int, decode static.EntityDecoder) error {
+
+		page := &ShowPage{}
+		if err := decode(page); err != nil {
+			return err
+		}
+		pages = append(pages, page)
+		return nil
+	})
+
+	if err != nil {
+		return []*ShowPage{}, err
+	}
+
+	return pages, nil
+}
+
+func GetShowPagesFromFile(path string) ([]*ShowPage, error) {
+
+	store, err := static.OpenFile(path)
+	if err != nil {
+		return []*ShowPage{}, err
+	}
+	defer store.Close()
+
+	return LoadPages(store)
+}
+
+func getShowPage(res *crawler.PageResult) (*ShowPage, error) {
+
+	id, err := crawler.GetEntityIDFromURL(res.URL)
+	if err != nil {
+		return &ShowPage{}, err
+	}
+
+	page := &ShowPage{
+		ID:               id,
+		URL:              res.URL,
+		Description:      getFirstText(res.Fields["description"]),
+		Provider:         getFirstText(res.Fields["provider"]),
+		MoreFromProvider: getLinkedShows(res.Fields["moreFromProvider"]),
+		AlsoSubscribed:   getLinkedShows(res.Fields["alsoSubscribed"]),
+		Episodes:         []*Episode{},
+	}
+
+	if len(res.Fields["website"]) > 0 {
+		page.Website = res.Fields["website"][0].Href
+	}
+
+	for _, el := range res.Fields["episodes"] {
+		episode := &Episode{Title: el.Text, URL: el.Href}
+		if parsed, err := crawler.ParseURL(el.Href); err == nil {
+			episode.ID = parsed.EpisodeID
+		}
+		page.Episodes = append(page.Episodes, episode)
+	}
+
+	return page, nil
+}
+
+func getFirstText(elements []*crawler.PageElement) string {
+
+	if len(elements) == 0 {
+		return ""
+	}
+	return elements[0].Text
+}
+
+func getLinkedShows(elements []*crawler.PageElement) []*Show {
+
+	shows := []*Show{}
+	for _, el := range elements {
+		id, err := crawler.GetEntityIDFromURL(el.Href)
+		if err != nil {
+			continue
+		}
+		shows = append(shows, NewShow(id, el.Href, el.Text))
+	}
+	return shows
+}
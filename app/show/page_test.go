@@ -0,0 +1,109 @@
+package show
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/zhikiri/itunes.podcasts/app/crawler"
+	"github.com/zhikiri/itunes.podcasts/app/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pageTemplate = `<!DOCTYPE html>
+<html>
+<body>
+<div class="product-header__identity"><a href="/artist/id9">Provider</a></div>
+<section class="product-hero-desc__section"><p>Show description</p></section>
+<ul class="product-hero__links"><li><a class="link" href="http://show.com">Show Website</a></li></ul>
+<ol>
+	<li class="tracks__track"><a class="tracks__track__link" href="/podcast/show/id1?i=11">Episode #1</a></li>
+	<li class="tracks__track"><a class="tracks__track__link" href="/podcast/show/id1?i=12">Episode #2</a></li>
+</ol>
+<section>
+	<h2>More by Provider</h2>
+	<a class="we-lockup__link" href="http://x.com/podcast/more/id2">More</a>
+</section>
+<section>
+	<h2>Listeners Also Subscribed To</h2>
+	<a class="we-lockup__link" href="http://x.com/podcast/also/id3">Also #3</a>
+	<a class="we-lockup__link" href="http://x.com/podcast/also/id4">Also #4</a>
+</section>
+</body>
+</html>
+`
+
+func newPageTestServer() *httptest.Server {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/podcast/show/id1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte(pageTemplate))
+	})
+
+	mux.HandleFunc("/podcast/missing/id2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(404)
+		w.Write([]byte("<p>error</p>"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGetPages(t *testing.T) {
+
+	ts := newPageTestServer()
+	defer ts.Close()
+
+	shows := []*Show{NewShow(1, ts.URL+"/podcast/show/id1", "Show")}
+	pages, errs := GetPages(GetPagesRequestOptions(shows, 0))
+
+	assert.Empty(t, errs)
+	assert.Len(t, pages, 1)
+
+	page := pages[0]
+	assert.Equal(t, 1, page.ID)
+	assert.Equal(t, "Show description", page.Description)
+	assert.Equal(t, "Provider", page.Provider)
+	assert.Equal(t, "http://show.com", page.Website)
+	assert.Equal(t, []*Show{NewShow(2, "http://x.com/podcast/more/id2", "More")}, page.MoreFromProvider)
+	assert.Len(t, page.AlsoSubscribed, 2)
+	assert.Len(t, page.Episodes, 2)
+	assert.Equal(t, "Episode #1", page.Episodes[0].Title)
+	assert.Equal(t, 11, page.Episodes[0].ID)
+	assert.Equal(t, 12, page.Episodes[1].ID)
+	assert.Equal(t, ts.URL+"/podcast/show/id1?i=11", page.Episodes[0].URL)
+
+	shows = []*Show{NewShow(2, ts.URL+"/podcast/missing/id2", "Missing")}
+	_, errs = GetPages(GetPagesRequestOptions(shows, 0))
+	assert.NotEmpty(t, errs)
+	assert.Equal(t, crawler.ErrorClassNotFound, crawler.GetErrorClass(errs[0]))
+}
+
+func TestGetShowPagesFromFile(t *testing.T) {
+
+	path := "/tmp/show-pages.test.json"
+
+	pages, err := GetShowPagesFromFile("/get/invalid/path")
+	assert.NotNil(t, err)
+	assert.Empty(t, pages)
+
+	func() {
+		pages = []*ShowPage{}
+		for i := 1; i <= 5; i++ {
+			pages = append(pages, &ShowPage{ID: i})
+		}
+		store, _ := static.OpenFile(path)
+		SavePages(store, pages)
+		store.Close()
+	}()
+
+	pages, err = GetShowPagesFromFile(path)
+	assert.Nil(t, err)
+	assert.Len(t, pages, 5)
+
+	os.Remove(path)
+}
@@ -1,17 +1,18 @@
 package show
 
 import (
-	"encoding/json"
-
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
 	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
 	"github.com/zhikiri/itunes.podcasts/app/static"
 )
 
+const Kind = "shows"
+
 type Show struct {
-	ID   int
-	URL  string
-	Name string
+	ID   int    `json:"id"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
 }
 
 func NewShow(id int, url string, name string) *Show {
@@ -19,34 +20,41 @@ func NewShow(id int, url string, name string) *Show {
 	return &Show{id, url, name}
 }
 
-func GetShowsRequestOptions(genres []*genre.Genre) *crawler.ScraperOptions {
+func GetShowsRequestOptions(genres []*genre.Genre, rule *rules.Rule) *crawler.ScraperOptions {
 
 	urls := []string{}
 	for _, genre := range genres {
 		urls = append(urls, genre.URL)
 	}
 
-	return crawler.GetScraperOptions(
-		urls,
-		"div[id=selectedcontent] .column a[href]",
-	)
+	return rule.GetScraperOptions(urls)
 }
 
-func Save(path string, shows []*Show) error {
-
-	return static.Save(path, func() ([]byte, error) {
+func Save(store static.Store, shows []*Show) error {
 
-		return json.Marshal(shows)
-	})
+	if err := store.Clear(Kind); err != nil {
+		return err
+	}
+	for _, show := range shows {
+		if err := store.Put(Kind, show.ID, show); err != nil {
+			return err
+		}
+	}
+	return store.Flush()
 }
 
-func GetShowsFromFile(path string) ([]*Show, error) {
+func LoadShows(store static.Store) ([]*Show, error) {
 
 	shows := []*Show{}
 
-	err := static.Load(path, func(body []byte) error {
+	err := store.Iterate(Kind, func(id int, decode static.EntityDecoder) error {
 
-		return json.Unmarshal(body, &shows)
+		show := &Show{}
+		if err := decode(show); err != nil {
+			return err
+		}
+		shows = append(shows, show)
+		return nil
 	})
 
 	if err != nil {
@@ -56,22 +64,32 @@ func GetShowsFromFile(path string) ([]*Show, error) {
 	return shows, nil
 }
 
-func GetShows(opt *crawler.ScraperOptions) ([]*Show, []error) {
+func GetShowsFromFile(path string) ([]*Show, error) {
 
-	res, err := crawler.ScrapeEntities(opt)
-	if len(err) > 0 {
+	store, err := static.OpenFile(path)
+	if err != nil {
 		return []*Show{}, err
 	}
+	defer store.Close()
+
+	return LoadShows(store)
+}
+
+// GetShows returns shows of the pages loaded successfully along with errors of the failed pages and shows
+func GetShows(opt *crawler.ScraperOptions) ([]*Show, []error) {
+
+	res, errs := crawler.ScrapeEntities(opt)
 
 	shows := []*Show{}
 	for name, url := range res {
 
-		id, err := crawler.GetEntityIDFromURL(url)
+		id, err := crawler.GetEntityIDByPattern(url, opt.IDPattern)
 		if err != nil {
-			return shows, []error{err}
+			errs = append(errs, err)
+			continue
 		}
 		shows = append(shows, NewShow(id, url, name))
 	}
 
-	return shows, []error{}
+	return shows, errs
 }
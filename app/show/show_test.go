@@ -1,8 +1,6 @@
 package show
 
 import (
-	"encoding/json"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,8 +8,9 @@ import (
 
 	"github.com/zhikiri/itunes.podcasts/app/crawler"
 	"github.com/zhikiri/itunes.podcasts/app/genre"
+	"github.com/zhikiri/itunes.podcasts/app/rules"
+	"github.com/zhikiri/itunes.podcasts/app/static"
 
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,12 +81,14 @@ func TestGetShowsRequestOptions(t *testing.T) {
 		genre.NewGenre(2, "http://x.com./gr/2", "Gr2"),
 		genre.NewGenre(3, "http://x.com./gr/3", "Gr3"),
 	}
-	opt := GetShowsRequestOptions(genres)
+	opt := GetShowsRequestOptions(genres, rules.Default().Get("show"))
 
 	for _, gen := range genres {
 		assert.Contains(t, opt.LookupURL, gen.URL)
 	}
 	assert.NotEmpty(t, opt.Pattern)
+	assert.NotEmpty(t, opt.Script)
+	assert.NotEmpty(t, opt.Match)
 }
 
 func TestGetShows(t *testing.T) {
@@ -110,13 +111,21 @@ func TestGetShows(t *testing.T) {
 		LookupURL: []string{ts.URL + "/invalid"},
 		Pattern:   ".target",
 	})
-	assert.Equal(t, "strconv.Atoi: parsing \"d\": invalid syntax", errors.Cause(err[0]).Error())
+	assert.Equal(t, "ID cannot be parsed from URL: http://x.com/podcasts-test1-first/idd", err[0].Error())
 
 	_, err = GetShows(&crawler.ScraperOptions{
 		LookupURL: []string{ts.URL + "/404"},
 		Pattern:   ".target",
 	})
-	assert.Equal(t, "Not Found", err[0].Error())
+	assert.Equal(t, crawler.ErrorClassNotFound, crawler.GetErrorClass(err[0]))
+
+	// shows of the loaded page are kept when the other page fails
+	shows, err = GetShows(&crawler.ScraperOptions{
+		LookupURL: []string{ts.URL, ts.URL + "/404"},
+		Pattern:   ".target",
+	})
+	assert.Len(t, err, 1)
+	assert.Equal(t, len(mocked), len(shows))
 }
 
 func TestGetShowsFromFile(t *testing.T) {
@@ -132,8 +141,9 @@ func TestGetShowsFromFile(t *testing.T) {
 		for i := 1; i <= 5; i++ {
 			sho = append(sho, NewShow(i, "http://x.com", "X"))
 		}
-		json, _ := json.Marshal(sho)
-		ioutil.WriteFile(path, json, 0644)
+		store, _ := static.OpenFile(path)
+		Save(store, sho)
+		store.Close()
 	}()
 
 	sho, err = GetShowsFromFile(path)
@@ -0,0 +1,156 @@
+package static
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Compressions lists supported compressions
+var Compressions = []string{CompressGzip, CompressZstd}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressWriter is implemented by both gzip and zstd writers
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// GetCompressionExt returns the file extension of the compression, e.g. ".gz"
+func GetCompressionExt(compression string) (string, error) {
+
+	switch compression {
+	case CompressNone:
+		return "", nil
+	case CompressGzip:
+		return ".gz", nil
+	case CompressZstd:
+		return ".zst", nil
+	}
+	return "", errors.Errorf("Unsupported compression: %s", compression)
+}
+
+// GetCompression returns the compression of the file by its extension
+func GetCompression(path string) string {
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return CompressGzip
+	case strings.HasSuffix(path, ".zst"):
+		return CompressZstd
+	}
+	return CompressNone
+}
+
+// TrimCompressionExt returns the path without the compression extension, e.g. "shows.json" for "shows.json.gz"
+func TrimCompressionExt(path string) string {
+
+	ext, _ := GetCompressionExt(GetCompression(path))
+	return strings.TrimSuffix(path, ext)
+}
+
+func compress(data []byte, compression string) ([]byte, error) {
+
+	if compression == CompressNone {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := newCompressWriter(buf, compression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, errors.Wrap(err, "Cannot compress data")
+	}
+	if err = w.Close(); err != nil {
+		return nil, errors.Wrap(err, "Cannot compress data")
+	}
+	return buf.Bytes(), nil
+}
+
+func newCompressWriter(w io.Writer, compression string) (compressWriter, error) {
+
+	switch compression {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot create zstd writer")
+		}
+		return enc, nil
+	}
+	return nil, errors.Errorf("Unsupported compression: %s", compression)
+}
+
+// newDecompressReader detects the compression by the magic bytes, so the file extension does not matter
+func newDecompressReader(r io.Reader) (io.ReadCloser, error) {
+
+	buf := bufio.NewReader(r)
+	head, _ := buf.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot read gzip data")
+		}
+		return gz, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		dec, err := zstd.NewReader(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot read zstd data")
+		}
+		return dec.IOReadCloser(), nil
+	}
+	return io.NopCloser(buf), nil
+}
+
+// readCloser closes both the decompressor and the file
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+
+	return closeAll(r.closers)
+}
+
+// writeCloser closes both the compressor and the file
+type writeCloser struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (w *writeCloser) Close() error {
+
+	return closeAll(w.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+
+	var err error
+	for _, c := range closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCompression(t *testing.T) {
+
+	assert.Equal(t, CompressGzip, GetCompression("/tmp/shows.json.gz"))
+	assert.Equal(t, CompressZstd, GetCompression("/tmp/shows.jsonl.zst"))
+	assert.Equal(t, CompressNone, GetCompression("/tmp/shows.json"))
+
+	assert.Equal(t, "/tmp/shows.jsonl", TrimCompressionExt("/tmp/shows.jsonl.gz"))
+	assert.Equal(t, "/tmp/shows.json", TrimCompressionExt("/tmp/shows.json"))
+}
+
+func TestSaveLoadCompressed(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	data := []byte(`[{"ID": 1, "Name": "one"}]`)
+	for _, name := range []string{"data.json.gz", "data.json.zst", "data.json"} {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, Save(path, func() ([]byte, error) { return data, nil }))
+
+		raw, _ := ioutil.ReadFile(path)
+		assert.Equal(t, GetCompression(path) == CompressNone, bytes.Equal(data, raw))
+
+		var body []byte
+		assert.Nil(t, Load(path, func(b []byte) error {
+			body = b
+			return nil
+		}))
+		assert.Equal(t, data, body)
+	}
+}
+
+func TestOpenMisleadingExt(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	// gzip content behind the plain extension is detected by the magic bytes
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte("{\"schema\": 2}\n{\"ID\": 1, \"Name\": \"one\"}\n"))
+	gz.Close()
+
+	path := filepath.Join(dir, "shows.jsonl")
+	ioutil.WriteFile(path, buf.Bytes(), 0644)
+
+	store, err := OpenFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []*testEntity{&testEntity{1, "one"}}, loadTestEntities(t, store, "any"))
+}
+
+func TestCreateCompressed(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lookup.jsonl.zst")
+	w, err := Create(path)
+	assert.Nil(t, err)
+	w.Write([]byte("{\"ID\": 1, \"Name\": \"one\"}\n"))
+	assert.Nil(t, w.Close())
+
+	reader, err := Open(path)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "{\"ID\": 1, \"Name\": \"one\"}\n", string(body))
+}
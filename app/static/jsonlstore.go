@@ -0,0 +1,466 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// JSONLStore keeps every kind as a JSON Lines file.
+// Entities are appended as they are put and read back with the streaming decoder, the latest line of the ID wins.
+// The first line of the file is the header.
+// Files with the compression extension (e.g. shows.jsonl.gz) are appended as compressed streams.
+// Cleared kinds are written to the temporary file, which replaces the kind file on flush.
+type JSONLStore struct {
+	mu      sync.Mutex
+	resolve func(kind string) string
+	header  *Header
+	files   map[string]*jsonlFile
+}
+
+type jsonlFile struct {
+	file *os.File
+	w    io.Writer
+	zw   compressWriter
+	// replaces is the kind file replaced by the temporary file on close
+	replaces string
+}
+
+func NewJSONLStore(dir string) *JSONLStore {
+
+	return newJSONLStore(dir, "", newHeader("", ""))
+}
+
+func newJSONLStore(dir string, ext string, header *Header) *JSONLStore {
+
+	return &JSONLStore{
+		resolve: func(kind string) string { return filepath.Join(dir, kind+".jsonl"+ext) },
+		header:  header,
+		files:   map[string]*jsonlFile{},
+	}
+}
+
+func newJSONLFileStore(path string) *JSONLStore {
+
+	store := NewJSONLStore("")
+	store.resolve = func(kind string) string { return path }
+	return store
+}
+
+func (s *JSONLStore) Put(kind string, id int, entity interface{}) error {
+
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode entity")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.file(kind)
+	if err != nil {
+		return err
+	}
+
+	// the whole line is written at once, so readers never see the partial entity
+	if _, err = file.w.Write(append(raw, '\n')); err != nil {
+		return errors.Wrap(err, "Cannot write entity")
+	}
+	return nil
+}
+
+func (s *JSONLStore) Get(kind string, id int, entity interface{}) error {
+
+	var found json.RawMessage
+	err := s.scan(kind, func(recID int, raw json.RawMessage) error {
+		if recID == id {
+			found = raw
+		}
+		return nil
+	})
+	if os.IsNotExist(errors.Cause(err)) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(found, entity)
+}
+
+func (s *JSONLStore) List(kind string) ([]int, error) {
+
+	last, err := s.index(kind)
+	if err != nil {
+		return []int{}, err
+	}
+
+	ids := make([]int, 0, len(last))
+	for id := range last {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return last[ids[i]] < last[ids[j]] })
+	return ids, nil
+}
+
+func (s *JSONLStore) Iterate(kind string, fn IterateFunc) error {
+
+	last, err := s.index(kind)
+	if err != nil {
+		return err
+	}
+
+	line := 0
+	return s.scan(kind, func(id int, raw json.RawMessage) error {
+		line++
+		if last[id] != line {
+			return nil
+		}
+		return fn(id, func(entity interface{}) error {
+			return json.Unmarshal(raw, entity)
+		})
+	})
+}
+
+func (s *JSONLStore) Clear(kind string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.closeFile(kind); err != nil {
+		return err
+	}
+
+	// the kind file is kept whole until the new entities are flushed
+	path := s.resolve(kind)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "Cannot create file")
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "Cannot create file")
+	}
+	file, err := s.open(tmp, GetCompression(path))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	file.replaces = path
+	s.files[kind] = file
+	return nil
+}
+
+func (s *JSONLStore) Flush() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for kind, file := range s.files {
+		if file.replaces != "" {
+			if err := s.closeFile(kind); err != nil {
+				return err
+			}
+			continue
+		}
+		if file.zw != nil {
+			if err := file.zw.Flush(); err != nil {
+				return errors.Wrapf(err, "Cannot flush %s", kind)
+			}
+		}
+		if err := file.file.Sync(); err != nil {
+			return errors.Wrapf(err, "Cannot flush %s", kind)
+		}
+	}
+	return nil
+}
+
+func (s *JSONLStore) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for kind := range s.files {
+		if cerr := s.closeFile(kind); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// index returns the line of the latest record per ID
+func (s *JSONLStore) index(kind string) (map[int]int, error) {
+
+	last := map[int]int{}
+	line := 0
+	err := s.scan(kind, func(id int, raw json.RawMessage) error {
+		line++
+		last[id] = line
+		return nil
+	})
+	return last, err
+}
+
+func (s *JSONLStore) scan(kind string, fn func(id int, raw json.RawMessage) error) error {
+
+	// buffered compressed lines are flushed, so they are visible for the reader
+	path := s.resolve(kind)
+	s.mu.Lock()
+	if file, ok := s.files[kind]; ok {
+		if file.zw != nil {
+			if err := file.zw.Flush(); err != nil {
+				s.mu.Unlock()
+				return errors.Wrapf(err, "Cannot flush %s", kind)
+			}
+		}
+		// entities of the cleared kind are read from the temporary file
+		if file.replaces != "" {
+			path = file.file.Name()
+		}
+	}
+	s.mu.Unlock()
+
+	reader, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dec := json.NewDecoder(reader)
+	for line := 0; ; line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF || errors.Cause(err) == io.ErrUnexpectedEOF {
+			// the torn line left by the interrupted write is skipped
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "Cannot decode record")
+		}
+
+		if line == 0 {
+			header, err := decodeHeader(raw)
+			if err != nil {
+				return err
+			}
+			if err = checkSchema(path, header); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id, err := getRecordID(raw)
+		if err != nil {
+			return err
+		}
+		if err = fn(id, raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *JSONLStore) file(kind string) (*jsonlFile, error) {
+
+	if file, ok := s.files[kind]; ok {
+		return file, nil
+	}
+
+	path := s.resolve(kind)
+	compression := GetCompression(path)
+	if compression != CompressNone {
+		if err := repairCompressed(path); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open file")
+	}
+
+	res, err := s.open(file, compression)
+	if err != nil {
+		return nil, err
+	}
+	s.files[kind] = res
+	return res, nil
+}
+
+// open prepares the opened file for appending, the header is written to the empty file
+func (s *JSONLStore) open(file *os.File, compression string) (*jsonlFile, error) {
+
+	var err error
+	res := &jsonlFile{file: file, w: file}
+	if compression == CompressNone {
+		err = truncateTornLine(file)
+	} else if res.zw, err = newCompressWriter(file, compression); err == nil {
+		res.w = res.zw
+	}
+	if err == nil {
+		err = s.writeHeader(res)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// writeHeader writes the header line to the empty file
+func (s *JSONLStore) writeHeader(file *jsonlFile) error {
+
+	stat, err := file.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "Cannot stat file")
+	}
+	if stat.Size() > 0 {
+		return checkJSONLHeader(file.file.Name())
+	}
+
+	raw, err := json.Marshal(s.header.renew())
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode header")
+	}
+	if _, err = file.w.Write(append(raw, '\n')); err != nil {
+		return errors.Wrap(err, "Cannot write header")
+	}
+	return nil
+}
+
+// checkJSONLHeader checks the schema of the existing file, so entities are not appended to the outdated one
+func checkJSONLHeader(path string) error {
+
+	reader, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var raw json.RawMessage
+	if err = json.NewDecoder(reader).Decode(&raw); err != nil {
+		// the torn header is truncated on open, any other error is reported on read
+		return nil
+	}
+	header, err := decodeHeader(raw)
+	if err != nil {
+		return err
+	}
+	return checkSchema(path, header)
+}
+
+// repairCompressed rewrites the compressed file left unfinished by the interrupted process,
+// otherwise the appended stream cannot be read after the broken one
+func repairCompressed(path string) error {
+
+	reader, err := Open(path)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := []json.RawMessage{}
+	dec := json.NewDecoder(reader)
+	for {
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			break
+		}
+		lines = append(lines, raw)
+	}
+	reader.Close()
+
+	if err == io.EOF {
+		return nil
+	}
+	return Save(path, func() ([]byte, error) {
+		buf := &bytes.Buffer{}
+		for _, raw := range lines {
+			buf.Write(raw)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// truncateTornLine drops the incomplete last line, so the next entity starts on the new line
+func truncateTornLine(file *os.File) error {
+
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "Cannot stat file")
+	}
+
+	size := stat.Size()
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err = file.ReadAt(chunk, start); err != nil {
+			return errors.Wrap(err, "Cannot read file")
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			if start+int64(i)+1 == size {
+				return nil
+			}
+			return file.Truncate(start + int64(i) + 1)
+		}
+		end = start
+	}
+	return file.Truncate(0)
+}
+
+func (s *JSONLStore) closeFile(kind string) error {
+
+	file, ok := s.files[kind]
+	if !ok {
+		return nil
+	}
+	delete(s.files, kind)
+
+	if file.replaces != "" {
+		return errors.Wrapf(replaceFile(file), "Cannot write %s", kind)
+	}
+
+	closers := []io.Closer{file.file}
+	if file.zw != nil {
+		closers = []io.Closer{file.zw, file.file}
+	}
+	return closeAll(closers)
+}
+
+// replaceFile finishes the temporary file of the cleared kind and renames it over the kind file
+func replaceFile(file *jsonlFile) error {
+
+	var err error
+	if file.zw != nil {
+		err = file.zw.Close()
+	}
+	if serr := file.file.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := file.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.file.Name(), file.replaces)
+	}
+	if err != nil {
+		os.Remove(file.file.Name())
+		return err
+	}
+	return SyncDir(filepath.Dir(file.replaces))
+}
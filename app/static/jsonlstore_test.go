@@ -0,0 +1,139 @@
+package static
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLStore(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	testStore(t, func() Store { return NewJSONLStore(dir) })
+
+	lines := readTestLines(t, filepath.Join(dir, "a.jsonl"))
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"schema":2`)
+	assert.Equal(t, `{"ID":4,"Name":"four"}`, lines[1])
+}
+
+func TestJSONLStoreStreaming(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	store := NewJSONLStore(dir)
+	store.Put("a", 1, &testEntity{1, "one"})
+	store.Put("a", 2, &testEntity{2, "two"})
+
+	// entities are visible right after put without flush
+	lines := readTestLines(t, filepath.Join(dir, "a.jsonl"))
+	assert.Equal(t, []string{`{"ID":1,"Name":"one"}`, `{"ID":2,"Name":"two"}`}, lines[1:])
+
+	store.Put("a", 1, &testEntity{1, "uno"})
+	ids, _ := store.List("a")
+	assert.Equal(t, []int{2, 1}, ids)
+
+	// the torn line of the interrupted write is skipped
+	file, _ := os.OpenFile(filepath.Join(dir, "a.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte("{\"ID\":3,\"Na"))
+	file.Close()
+
+	assert.Equal(t, []*testEntity{&testEntity{2, "two"}, &testEntity{1, "uno"}}, loadTestEntities(t, store, "a"))
+	store.Close()
+
+	store = NewJSONLStore(dir)
+	store.Put("a", 4, &testEntity{4, "four"})
+	assert.Equal(t, []*testEntity{&testEntity{2, "two"}, &testEntity{1, "uno"}, &testEntity{4, "four"}}, loadTestEntities(t, store, "a"))
+	store.Close()
+}
+
+func TestJSONLStoreCompressed(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, ext := range []string{".gz", ".zst"} {
+		store := newJSONLStore(dir, ext, newHeader("", ""))
+		store.Put("a", 1, &testEntity{1, "one"})
+		assert.Nil(t, store.Flush())
+
+		// flushed entities are readable, while the stream is not finished
+		assert.Equal(t, []*testEntity{&testEntity{1, "one"}}, loadTestEntities(t, store, "a"))
+
+		// the store is not closed like after the crash, the unfinished stream is repaired on append
+		store.Put("a", 2, &testEntity{2, "two"})
+		store.Flush()
+
+		store = newJSONLStore(dir, ext, newHeader("", ""))
+		store.Put("a", 3, &testEntity{3, "three"})
+		assert.Nil(t, store.Close())
+
+		store = newJSONLStore(dir, ext, newHeader("", ""))
+		store.Put("a", 4, &testEntity{4, "four"})
+		assert.Nil(t, store.Close())
+
+		assert.Equal(t, []*testEntity{
+			&testEntity{1, "one"}, &testEntity{2, "two"}, &testEntity{3, "three"}, &testEntity{4, "four"},
+		}, loadTestEntities(t, newJSONLStore(dir, ext, newHeader("", "")), "a"))
+	}
+}
+
+func TestJSONLStoreClear(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, ext := range []string{"", ".gz"} {
+		path := filepath.Join(dir, "a.jsonl"+ext)
+		store := newJSONLStore(dir, ext, newHeader("", ""))
+		store.Put("a", 1, &testEntity{1, "one"})
+		assert.Nil(t, store.Close())
+
+		// the kind file is kept until the entities of the cleared kind are flushed
+		store = newJSONLStore(dir, ext, newHeader("", ""))
+		assert.Nil(t, store.Clear("a"))
+		store.Put("a", 2, &testEntity{2, "two"})
+		assert.Equal(t, []*testEntity{&testEntity{1, "one"}}, loadTestEntities(t, newJSONLStore(dir, ext, newHeader("", "")), "a"))
+		assert.Equal(t, []*testEntity{&testEntity{2, "two"}}, loadTestEntities(t, store, "a"))
+
+		assert.Nil(t, store.Flush())
+		assert.Equal(t, []*testEntity{&testEntity{2, "two"}}, loadTestEntities(t, newJSONLStore(dir, ext, newHeader("", "")), "a"))
+		tmp, _ := filepath.Glob(path + ".*.tmp")
+		assert.Empty(t, tmp)
+
+		// entities put after the flush are appended to the replaced file
+		store.Put("a", 3, &testEntity{3, "three"})
+		assert.Nil(t, store.Close())
+		assert.Equal(t, []*testEntity{&testEntity{2, "two"}, &testEntity{3, "three"}}, loadTestEntities(t, newJSONLStore(dir, ext, newHeader("", "")), "a"))
+	}
+}
+
+func TestJSONLStoreLegacy(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.jsonl")
+	ioutil.WriteFile(path, []byte("{\"ID\":1,\"Name\":\"one\"}\n"), 0644)
+
+	store := NewJSONLStore(dir)
+	_, err := store.List("a")
+	assert.Equal(t, &SchemaError{path, 1}, err)
+
+	// entities are not appended to the outdated file
+	assert.Equal(t, &SchemaError{path, 1}, store.Put("a", 2, &testEntity{2, "two"}))
+}
+
+func readTestLines(t *testing.T, path string) []string {
+
+	body, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	return strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+}
@@ -0,0 +1,307 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// JSONStore keeps every kind as a JSON object with the header and the records array in a separate file,
+// changes are written on flush.
+// Files with the compression extension (e.g. shows.json.gz) are compressed on write.
+type JSONStore struct {
+	mu      sync.Mutex
+	resolve func(kind string) string
+	header  *Header
+	kinds   map[string]*collection
+	headers map[string]*Header
+	dirty   map[string]bool
+}
+
+// jsonEnvelope is the layout of the JSON file
+type jsonEnvelope struct {
+	Header
+	Records []json.RawMessage `json:"records"`
+}
+
+func NewJSONStore(dir string) *JSONStore {
+
+	return newJSONStore(dir, "", newHeader("", ""))
+}
+
+func newJSONStore(dir string, ext string, header *Header) *JSONStore {
+
+	return &JSONStore{
+		resolve: func(kind string) string { return filepath.Join(dir, kind+".json"+ext) },
+		header:  header,
+		kinds:   map[string]*collection{},
+		headers: map[string]*Header{},
+		dirty:   map[string]bool{},
+	}
+}
+
+func newJSONFileStore(path string) *JSONStore {
+
+	store := NewJSONStore("")
+	store.resolve = func(kind string) string { return path }
+	return store
+}
+
+func (s *JSONStore) Put(kind string, id int, entity interface{}) error {
+
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode entity")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	col, err := s.load(kind, false)
+	if err != nil {
+		return err
+	}
+	col.put(id, raw)
+	s.dirty[kind] = true
+	return nil
+}
+
+func (s *JSONStore) Get(kind string, id int, entity interface{}) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	col, err := s.load(kind, false)
+	if err != nil {
+		return err
+	}
+	return col.get(id, entity)
+}
+
+func (s *JSONStore) List(kind string) ([]int, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	col, err := s.load(kind, true)
+	if err != nil {
+		return []int{}, err
+	}
+	return col.list(), nil
+}
+
+func (s *JSONStore) Iterate(kind string, fn IterateFunc) error {
+
+	s.mu.Lock()
+	col, loaded := s.kinds[kind]
+	s.mu.Unlock()
+
+	if loaded {
+		return col.iterate(fn)
+	}
+	return s.stream(kind, fn)
+}
+
+func (s *JSONStore) Clear(kind string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.kinds[kind] = newCollection()
+	delete(s.headers, kind)
+	s.dirty[kind] = true
+	return nil
+}
+
+func (s *JSONStore) Flush() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for kind := range s.dirty {
+		col := s.kinds[kind]
+		env := &jsonEnvelope{Records: make([]json.RawMessage, 0, len(col.order))}
+		for _, id := range col.order {
+			env.Records = append(env.Records, col.records[id])
+		}
+
+		header, ok := s.headers[kind]
+		if !ok {
+			header = s.header.renew()
+			s.headers[kind] = header
+		}
+		env.Header = *header
+
+		err := Save(s.resolve(kind), func() ([]byte, error) {
+			return json.Marshal(env)
+		})
+		if err != nil {
+			return err
+		}
+		delete(s.dirty, kind)
+	}
+	return nil
+}
+
+func (s *JSONStore) Close() error {
+
+	return s.Flush()
+}
+
+func (s *JSONStore) load(kind string, mustExist bool) (*collection, error) {
+
+	if col, ok := s.kinds[kind]; ok {
+		return col, nil
+	}
+
+	col := newCollection()
+	path := s.resolve(kind)
+	if _, err := os.Stat(path); os.IsNotExist(err) && !mustExist {
+		s.kinds[kind] = col
+		return col, nil
+	}
+
+	var env *jsonEnvelope
+	err := Load(path, func(body []byte) (err error) {
+		env, err = decodeJSONEnvelope(path, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSchema(path, &env.Header); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range env.Records {
+		id, err := getRecordID(raw)
+		if err != nil {
+			return nil, err
+		}
+		col.put(id, raw)
+	}
+
+	s.kinds[kind] = col
+	s.headers[kind] = &env.Header
+	return col, nil
+}
+
+// stream decodes the records one by one without loading the whole file
+func (s *JSONStore) stream(kind string, fn IterateFunc) error {
+
+	path := s.resolve(kind)
+	reader, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dec := json.NewDecoder(reader)
+	if tok, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "Cannot decode records")
+	} else if tok == json.Delim('[') {
+		return &SchemaError{path, 1}
+	} else if tok != json.Delim('{') {
+		return errors.New("Cannot decode records: object is expected")
+	}
+
+	// header fields are written before records, so the schema is checked before the first record
+	fields := map[string]json.RawMessage{}
+	checked := false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return errors.Wrap(err, "Cannot decode records")
+		}
+
+		if key, _ := tok.(string); key != "records" {
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err != nil {
+				return errors.Wrap(err, "Cannot decode header")
+			}
+			fields[key] = raw
+			continue
+		}
+
+		if err = checkFieldsSchema(path, fields); err != nil {
+			return err
+		}
+		checked = true
+		if err = streamRecords(dec, fn); err != nil {
+			return err
+		}
+	}
+
+	if !checked {
+		return checkFieldsSchema(path, fields)
+	}
+	return nil
+}
+
+func streamRecords(dec *json.Decoder, fn IterateFunc) error {
+
+	if tok, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "Cannot decode records")
+	} else if tok != json.Delim('[') {
+		return errors.New("Cannot decode records: array is expected")
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return errors.Wrap(err, "Cannot decode record")
+		}
+
+		id, err := getRecordID(raw)
+		if err != nil {
+			return err
+		}
+		err = fn(id, func(entity interface{}) error {
+			return json.Unmarshal(raw, entity)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+func checkFieldsSchema(path string, fields map[string]json.RawMessage) error {
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return errors.Wrap(err, "Cannot decode header")
+	}
+	header, err := decodeHeader(raw)
+	if err != nil {
+		return err
+	}
+	return checkSchema(path, header)
+}
+
+// decodeJSONEnvelope decodes the JSON file, the plain records array is the file of schema version 1
+func decodeJSONEnvelope(path string, body []byte) (*jsonEnvelope, error) {
+
+	env := &jsonEnvelope{}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &env.Records); err != nil {
+			return nil, errors.Wrap(err, "Cannot decode records")
+		}
+		env.Schema = 1
+		return env, nil
+	}
+
+	if err := json.Unmarshal(body, env); err != nil {
+		return nil, errors.Wrap(err, "Cannot decode records")
+	}
+	if env.Schema == 0 {
+		return nil, errors.Errorf("File %s is not a store file", path)
+	}
+	return env, nil
+}
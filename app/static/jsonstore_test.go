@@ -0,0 +1,48 @@
+package static
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONStore(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	testStore(t, func() Store { return NewJSONStore(dir) })
+
+	body, err := ioutil.ReadFile(filepath.Join(dir, "a.json"))
+	assert.Nil(t, err)
+
+	env := &jsonEnvelope{}
+	assert.Nil(t, json.Unmarshal(body, env))
+	assert.Equal(t, SchemaVersion, env.Schema)
+	assert.Equal(t, DefaultGenerator, env.Generator)
+	assert.False(t, env.Created.IsZero())
+	assert.Equal(t, `[{"ID":4,"Name":"four"}]`, string(mustMarshal(env.Records)))
+}
+
+func TestJSONStoreLegacy(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"ID":1,"Name":"one"}]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"schema":3,"records":[]}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "c.json"), []byte(`{"version":1}`), 0644)
+
+	store := NewJSONStore(dir)
+	_, err := store.List("a")
+	assert.Equal(t, &SchemaError{filepath.Join(dir, "a.json"), 1}, err)
+	assert.Equal(t, &SchemaError{filepath.Join(dir, "a.json"), 1}, store.Iterate("a", nil))
+	assert.Equal(t, &SchemaError{filepath.Join(dir, "b.json"), 3}, store.Iterate("b", nil))
+
+	_, err = store.List("c")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,323 @@
+package static
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// LogStore keeps all kinds in the single append-only log file, the first record is the header.
+// Every record is framed with its length and checksum, so the torn tail left by a crash is dropped on open.
+type LogStore struct {
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	header *Header
+	kinds  map[string]*logIndex
+	// readOnly log is neither repaired nor appended
+	readOnly bool
+}
+
+type logIndex struct {
+	order   []int
+	offsets map[int]logOffset
+}
+
+type logOffset struct {
+	at   int64
+	size int
+}
+
+type logRecord struct {
+	Kind  string          `json:"k"`
+	ID    int             `json:"i"`
+	Data  json.RawMessage `json:"d,omitempty"`
+	Clear bool            `json:"c,omitempty"`
+}
+
+const (
+	logHeaderSize = 8
+	logMaxRecord  = 64 << 20
+	logHeaderKind = "_header"
+)
+
+func NewLogStore(path string) (*LogStore, error) {
+
+	return newLogStore(path, newHeader("", ""), true)
+}
+
+// newLogStore opens the log, the header is written to the new log, the schema of the existing log is checked on demand
+func newLogStore(path string, header *Header, check bool) (*LogStore, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open log")
+	}
+
+	store := &LogStore{file: file, kinds: map[string]*logIndex{}}
+	if err = store.replay(); err == nil {
+		err = store.init(path, header, check)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// OpenLogReader opens the existing log for reading only, the torn tail is skipped but kept in the file
+func OpenLogReader(path string) (*LogStore, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open log")
+	}
+
+	store := &LogStore{file: file, kinds: map[string]*logIndex{}, readOnly: true}
+	if err = store.replay(); err == nil && store.size > 0 {
+		err = store.init(path, nil, true)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *LogStore) init(path string, header *Header, check bool) error {
+
+	if s.size == 0 {
+		raw, err := json.Marshal(header.renew())
+		if err != nil {
+			return errors.Wrap(err, "Cannot encode header")
+		}
+		return s.append(&logRecord{Kind: logHeaderKind, Data: raw})
+	}
+	if !check {
+		return nil
+	}
+	if s.header == nil {
+		return &SchemaError{path, 1}
+	}
+	return checkSchema(path, s.header)
+}
+
+func (s *LogStore) Put(kind string, id int, entity interface{}) error {
+
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode entity")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.append(&logRecord{Kind: kind, ID: id, Data: raw})
+}
+
+func (s *LogStore) Get(kind string, id int, entity interface{}) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, ok := s.kinds[kind]
+	if !ok {
+		return ErrNotFound
+	}
+	off, ok := idx.offsets[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	rec, err := s.read(off)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(rec.Data, entity)
+}
+
+func (s *LogStore) List(kind string) ([]int, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, ok := s.kinds[kind]
+	if !ok {
+		return []int{}, nil
+	}
+	return append([]int{}, idx.order...), nil
+}
+
+func (s *LogStore) Iterate(kind string, fn IterateFunc) error {
+
+	ids, _ := s.List(kind)
+	for _, id := range ids {
+		err := fn(id, func(entity interface{}) error {
+			return s.Get(kind, id, entity)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LogStore) Clear(kind string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.append(&logRecord{Kind: kind, Clear: true})
+}
+
+func (s *LogStore) Flush() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return nil
+	}
+	return s.file.Sync()
+}
+
+func (s *LogStore) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if !s.readOnly {
+		err = s.file.Sync()
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// kindNames returns kinds which have records
+func (s *LogStore) kindNames() []string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := []string{}
+	for kind, idx := range s.kinds {
+		if len(idx.order) > 0 {
+			res = append(res, kind)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (s *LogStore) append(rec *logRecord) error {
+
+	if s.readOnly {
+		return errors.Errorf("Log %s is opened for reading only", s.file.Name())
+	}
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode record")
+	}
+
+	frame := make([]byte, logHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[logHeaderSize:], payload)
+
+	if _, err = s.file.WriteAt(frame, s.size); err != nil {
+		return errors.Wrap(err, "Cannot append record")
+	}
+
+	s.index(rec, logOffset{s.size + logHeaderSize, len(payload)})
+	s.size += int64(len(frame))
+	return nil
+}
+
+func (s *LogStore) read(off logOffset) (*logRecord, error) {
+
+	payload := make([]byte, off.size)
+	if _, err := s.file.ReadAt(payload, off.at); err != nil {
+		return nil, errors.Wrap(err, "Cannot read record")
+	}
+
+	rec := &logRecord{}
+	if err := json.Unmarshal(payload, rec); err != nil {
+		return nil, errors.Wrap(err, "Cannot decode record")
+	}
+	return rec, nil
+}
+
+func (s *LogStore) index(rec *logRecord, off logOffset) {
+
+	if rec.Kind == logHeaderKind {
+		if off.at == logHeaderSize {
+			s.header, _ = decodeHeader(rec.Data)
+		}
+		return
+	}
+
+	idx, ok := s.kinds[rec.Kind]
+	if !ok || rec.Clear {
+		idx = &logIndex{[]int{}, map[int]logOffset{}}
+		s.kinds[rec.Kind] = idx
+	}
+	if rec.Clear {
+		return
+	}
+
+	if _, ok := idx.offsets[rec.ID]; !ok {
+		idx.order = append(idx.order, rec.ID)
+	}
+	idx.offsets[rec.ID] = off
+}
+
+func (s *LogStore) replay() error {
+
+	reader := bufio.NewReader(s.file)
+	header := make([]byte, logHeaderSize)
+
+	var offset int64
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > logMaxRecord {
+			break
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+
+		rec := &logRecord{}
+		if err := json.Unmarshal(payload, rec); err != nil {
+			break
+		}
+
+		s.index(rec, logOffset{offset + logHeaderSize, int(size)})
+		offset += logHeaderSize + int64(size)
+	}
+
+	// drop the torn tail, so the next records are appended right after the last valid one
+	s.size = offset
+	if s.readOnly {
+		return nil
+	}
+	return s.file.Truncate(offset)
+}
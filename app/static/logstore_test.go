@@ -0,0 +1,100 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogStore(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	testStore(t, func() Store {
+		store, err := NewLogStore(path)
+		assert.Nil(t, err)
+		return store
+	})
+}
+
+func TestLogStoreTornTail(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	store, _ := NewLogStore(path)
+	store.Put("a", 1, &testEntity{1, "one"})
+	store.Put("a", 2, &testEntity{2, "two"})
+	store.Close()
+
+	// emulate the crash in the middle of the record write
+	stat, _ := os.Stat(path)
+	os.Truncate(path, stat.Size()-3)
+
+	store, err := NewLogStore(path)
+	assert.Nil(t, err)
+	ids, _ := store.List("a")
+	assert.Equal(t, []int{1}, ids)
+
+	assert.Nil(t, store.Put("a", 3, &testEntity{3, "three"}))
+	store.Close()
+
+	store, _ = NewLogStore(path)
+	assert.Equal(t, []*testEntity{&testEntity{1, "one"}, &testEntity{3, "three"}}, loadTestEntities(t, store, "a"))
+	store.Close()
+}
+
+func TestOpenLogReader(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	// the missing log is not created
+	path := filepath.Join(dir, "test.log")
+	_, err := OpenLogReader(path)
+	assert.NotNil(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	store, _ := NewLogStore(path)
+	store.Put("a", 1, &testEntity{1, "one"})
+	store.Put("a", 2, &testEntity{2, "two"})
+	store.Close()
+
+	// the torn tail is skipped but kept for the writer to repair
+	stat, _ := os.Stat(path)
+	os.Truncate(path, stat.Size()-3)
+
+	reader, err := OpenLogReader(path)
+	assert.Nil(t, err)
+	ids, _ := reader.List("a")
+	assert.Equal(t, []int{1}, ids)
+	assert.NotNil(t, reader.Put("a", 3, &testEntity{3, "three"}))
+	assert.NotNil(t, reader.Clear("a"))
+	assert.Nil(t, reader.Close())
+
+	torn, _ := os.Stat(path)
+	assert.Equal(t, stat.Size()-3, torn.Size())
+}
+
+func TestLogStoreLegacy(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	// the log of schema version 1 has no header record
+	path := filepath.Join(dir, "test.log")
+	store, _ := newLogStore(path, newHeader("", ""), false)
+	store.file.Truncate(0)
+	store.size = 0
+	store.append(&logRecord{Kind: "a", ID: 1, Data: []byte(`{"ID":1}`)})
+	store.Close()
+
+	_, err := NewLogStore(path)
+	assert.Equal(t, &SchemaError{path, 1}, err)
+}
@@ -0,0 +1,183 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MigrateFile upgrades the store file to the current schema version and returns the version it had.
+// The format is chosen by the file extension like in OpenFile, the header of the upgraded file
+// is created with the given generator and country.
+func MigrateFile(path string, generator string, country string) (int, error) {
+
+	header := newHeader(generator, country)
+	switch base := TrimCompressionExt(path); {
+	case strings.HasSuffix(base, ".jsonl"):
+		return migrateJSONL(path, header)
+	case strings.HasSuffix(base, ".log"):
+		return migrateLog(path, header)
+	}
+	return migrateJSON(path, header)
+}
+
+func migrateJSON(path string, header *Header) (int, error) {
+
+	var env *jsonEnvelope
+	err := Load(path, func(body []byte) (err error) {
+		env, err = decodeJSONEnvelope(path, body)
+		return err
+	})
+	if err != nil || env.Schema == SchemaVersion {
+		return env.getSchema(), err
+	}
+	if env.Schema > SchemaVersion {
+		return env.Schema, &SchemaError{path, env.Schema}
+	}
+
+	from := env.Schema
+	if env.Records, err = migrateRecords(env.Records, from); err != nil {
+		return from, err
+	}
+	env.Header = *header
+
+	return from, Save(path, func() ([]byte, error) {
+		return json.Marshal(env)
+	})
+}
+
+func migrateJSONL(path string, header *Header) (int, error) {
+
+	reader, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := []json.RawMessage{}
+	dec := json.NewDecoder(reader)
+	for {
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err == io.EOF || errors.Cause(err) == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			reader.Close()
+			return 0, errors.Wrap(err, "Cannot decode record")
+		}
+		lines = append(lines, raw)
+	}
+	reader.Close()
+
+	from := 1
+	if len(lines) > 0 {
+		current, err := decodeHeader(lines[0])
+		if err != nil {
+			return 0, err
+		}
+		if from = current.Schema; from == SchemaVersion {
+			return from, nil
+		}
+		if from > SchemaVersion {
+			return from, &SchemaError{path, from}
+		}
+		if from > 1 {
+			lines = lines[1:]
+		}
+	}
+
+	if lines, err = migrateRecords(lines, from); err != nil {
+		return from, err
+	}
+
+	return from, Save(path, func() ([]byte, error) {
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(header); err != nil {
+			return nil, err
+		}
+		for _, raw := range lines {
+			buf.Write(raw)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// migrateLog writes the live records of the log to the new log, which replaces the old one
+func migrateLog(path string, header *Header) (int, error) {
+
+	if _, err := os.Stat(path); err != nil {
+		return 0, errors.Wrap(err, "File is not exists")
+	}
+
+	src, err := newLogStore(path, header, false)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	from := 1
+	if src.header != nil {
+		from = src.header.Schema
+	}
+	if from == SchemaVersion {
+		return from, nil
+	}
+	if from > SchemaVersion {
+		return from, &SchemaError{path, from}
+	}
+
+	tmp := path + ".migrate"
+	os.Remove(tmp)
+	dst, err := newLogStore(tmp, header, false)
+	if err != nil {
+		return from, err
+	}
+
+	for _, kind := range src.kindNames() {
+		err = src.Iterate(kind, func(id int, decode EntityDecoder) error {
+			var raw json.RawMessage
+			if err := decode(&raw); err != nil {
+				return err
+			}
+			if raw, err = migrateRecord(raw, from); err != nil {
+				return err
+			}
+			return dst.Put(kind, id, raw)
+		})
+		if err != nil {
+			dst.Close()
+			os.Remove(tmp)
+			return from, err
+		}
+	}
+
+	if err = dst.Close(); err != nil {
+		os.Remove(tmp)
+		return from, err
+	}
+	return from, errors.Wrap(os.Rename(tmp, path), "Cannot replace log")
+}
+
+func migrateRecords(records []json.RawMessage, from int) ([]json.RawMessage, error) {
+
+	res := make([]json.RawMessage, 0, len(records))
+	for _, raw := range records {
+		raw, err := migrateRecord(raw, from)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, raw)
+	}
+	return res, nil
+}
+
+func (env *jsonEnvelope) getSchema() int {
+
+	if env == nil {
+		return 0
+	}
+	return env.Schema
+}
@@ -0,0 +1,83 @@
+package static
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type migratedEntity struct {
+	ID       int    `json:"id"`
+	ShowName string `json:"show_name"`
+}
+
+func loadMigratedEntities(t *testing.T, path string) []*migratedEntity {
+
+	store, err := OpenFile(path)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	res := []*migratedEntity{}
+	err = store.Iterate("a", func(id int, decode EntityDecoder) error {
+		en := &migratedEntity{}
+		res = append(res, en)
+		return decode(en)
+	})
+	assert.Nil(t, err)
+	return res
+}
+
+func TestMigrateFile(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	expected := []*migratedEntity{&migratedEntity{1, "one"}, &migratedEntity{2, "two"}}
+	files := map[string]string{
+		"a.json":     `[{"ID":1,"ShowName":"one"},{"ID":2,"ShowName":"two"}]`,
+		"a.jsonl.gz": "{\"ID\":1,\"ShowName\":\"one\"}\n{\"ID\":2,\"ShowName\":\"two\"}\n",
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		Save(path, func() ([]byte, error) { return []byte(body), nil })
+
+		from, err := MigrateFile(path, "itupod test", "ua")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, from)
+		assert.Equal(t, expected, loadMigratedEntities(t, path))
+
+		from, err = MigrateFile(path, "itupod test", "ua")
+		assert.Nil(t, err)
+		assert.Equal(t, SchemaVersion, from)
+	}
+
+	// the log of schema version 1 has no header record
+	path := filepath.Join(dir, "a.log")
+	log, _ := newLogStore(path, newHeader("", ""), false)
+	log.file.Truncate(0)
+	log.size = 0
+	log.append(&logRecord{Kind: "a", ID: 1, Data: []byte(`{"ID":1,"ShowName":"uno"}`)})
+	log.append(&logRecord{Kind: "a", ID: 2, Data: []byte(`{"ID":2,"ShowName":"two"}`)})
+	log.append(&logRecord{Kind: "a", ID: 1, Data: []byte(`{"ID":1,"ShowName":"one"}`)})
+	log.Close()
+
+	from, err := MigrateFile(path, "itupod test", "ua")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, from)
+	assert.Equal(t, expected, loadMigratedEntities(t, path))
+
+	log, _ = NewLogStore(path)
+	assert.Equal(t, "ua", log.header.Country)
+	assert.Equal(t, "itupod test", log.header.Generator)
+	log.Close()
+
+	ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"schema":3,"records":[]}`), 0644)
+	_, err = MigrateFile(filepath.Join(dir, "b.json"), "itupod test", "ua")
+	assert.Equal(t, &SchemaError{filepath.Join(dir, "b.json"), 3}, err)
+
+	_, err = MigrateFile(filepath.Join(dir, "missing.json"), "itupod test", "ua")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,154 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaVersion is the version of the stored files layout.
+// Version 1 files have no header and use Go field names, version 2 files have the header and snake_case field names.
+const SchemaVersion = 2
+
+// Header describes the stored file, it is written once when the file is created
+type Header struct {
+	Schema    int       `json:"schema"`
+	Generator string    `json:"generator"`
+	Created   time.Time `json:"created"`
+	Country   string    `json:"country,omitempty"`
+}
+
+// DefaultGenerator is written to headers of files created by stores without explicit options
+const DefaultGenerator = "itupod"
+
+// SchemaError is returned when the file schema differs from the current one
+type SchemaError struct {
+	Path    string
+	Version int
+}
+
+func (e *SchemaError) Error() string {
+	if e.Version < SchemaVersion {
+		return fmt.Sprintf("File %s has outdated schema version %d, run migrate to upgrade it to %d", e.Path, e.Version, SchemaVersion)
+	}
+	return fmt.Sprintf("File %s has schema version %d which is not supported, latest is %d", e.Path, e.Version, SchemaVersion)
+}
+
+// newHeader returns the header of the new file
+func newHeader(generator string, country string) *Header {
+
+	if generator == "" {
+		generator = DefaultGenerator
+	}
+	return &Header{
+		Schema:    SchemaVersion,
+		Generator: generator,
+		Created:   time.Now().UTC(),
+		Country:   country,
+	}
+}
+
+// renew returns the copy of the header for the new file
+func (h *Header) renew() *Header {
+
+	return newHeader(h.Generator, h.Country)
+}
+
+func checkSchema(path string, header *Header) error {
+
+	if header.Schema != SchemaVersion {
+		return &SchemaError{path, header.Schema}
+	}
+	return nil
+}
+
+// decodeHeader decodes the header line or record, records without the schema are reported as version 1
+func decodeHeader(raw json.RawMessage) (*Header, error) {
+
+	header := &Header{}
+	if err := json.Unmarshal(raw, header); err != nil {
+		return nil, errors.Wrap(err, "Cannot decode header")
+	}
+	if header.Schema == 0 {
+		header.Schema = 1
+	}
+	return header, nil
+}
+
+// migrations upgrade the record of the version to the next one
+var migrations = map[int]func(raw json.RawMessage) (json.RawMessage, error){
+	1: migrateSnakeCase,
+}
+
+// migrateRecord upgrades the record from the version to the current one
+func migrateRecord(raw json.RawMessage, version int) (json.RawMessage, error) {
+
+	var err error
+	for v := version; v < SchemaVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return nil, errors.Errorf("Migration from schema version %d is not supported", v)
+		}
+		if raw, err = migrate(raw); err != nil {
+			return nil, errors.Wrapf(err, "Cannot migrate record from schema version %d", v)
+		}
+	}
+	return raw, nil
+}
+
+// migrateSnakeCase renames Go field names of the record to snake_case, e.g. LastPodcast to last_podcast
+func migrateSnakeCase(raw json.RawMessage) (json.RawMessage, error) {
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(getSnakeCaseKeys(value))
+}
+
+func getSnakeCaseKeys(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			res[getSnakeCase(key)] = getSnakeCaseKeys(val)
+		}
+		return res
+	case []interface{}:
+		for i, val := range v {
+			v[i] = getSnakeCaseKeys(val)
+		}
+		return v
+	}
+	return value
+}
+
+// getSnakeCase converts the Go field name to snake_case, acronyms are kept together, e.g. ShowURL to show_url
+func getSnakeCase(name string) string {
+
+	runes := []rune(name)
+	var res strings.Builder
+	for i, r := range runes {
+		if !unicode.IsUpper(r) {
+			res.WriteRune(r)
+			continue
+		}
+		if i > 0 {
+			prev := runes[i-1]
+			acronymEnd := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || acronymEnd {
+				res.WriteByte('_')
+			}
+		}
+		res.WriteRune(unicode.ToLower(r))
+	}
+	return res.String()
+}
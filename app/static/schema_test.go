@@ -0,0 +1,48 @@
+package static
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSnakeCase(t *testing.T) {
+
+	tests := map[string]string{
+		"ID":               "id",
+		"RSS":              "rss",
+		"Name":             "name",
+		"ShowURL":          "show_url",
+		"LastPodcast":      "last_podcast",
+		"MoreFromProvider": "more_from_provider",
+		"FetchedAt":        "fetched_at",
+		"show_url":         "show_url",
+		"artworkURL30":     "artwork_url30",
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, getSnakeCase(name))
+	}
+}
+
+func TestMigrateRecord(t *testing.T) {
+
+	raw, err := migrateRecord([]byte(`{"ID":1,"Image":{"Big":"b"},"Episodes":[{"URL":"u"}],"Genres":["Arts"]}`), 1)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":1,"image":{"big":"b"},"episodes":[{"url":"u"}],"genres":["Arts"]}`, string(raw))
+
+	raw, err = migrateRecord([]byte(`{"ID":1}`), SchemaVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"ID":1}`, string(raw))
+
+	_, err = migrateRecord([]byte(`{`), 1)
+	assert.NotNil(t, err)
+}
+
+func TestSchemaError(t *testing.T) {
+
+	err := &SchemaError{"shows.json", 1}
+	assert.Equal(t, "File shows.json has outdated schema version 1, run migrate to upgrade it to 2", err.Error())
+
+	err = &SchemaError{"shows.json", 3}
+	assert.Equal(t, "File shows.json has schema version 3 which is not supported, latest is 2", err.Error())
+}
@@ -0,0 +1,114 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SourceStore reads every kind from the file found in the folder whatever format and compression it has,
+// e.g. shows.json is read by the store opened with gzip compression.
+// Kinds without files and all writes go to the wrapped store.
+type SourceStore struct {
+	Store
+	mu    sync.Mutex
+	dir   string
+	files map[string]Store
+}
+
+// OpenSource wraps the store of the folder, the wrapped store is not closed by the source
+func OpenSource(dir string, store Store) *SourceStore {
+
+	return &SourceStore{Store: store, dir: dir, files: map[string]Store{}}
+}
+
+func (s *SourceStore) Get(kind string, id int, entity interface{}) error {
+
+	store, err := s.resolve(kind)
+	if err != nil {
+		return err
+	}
+	return store.Get(kind, id, entity)
+}
+
+func (s *SourceStore) List(kind string) ([]int, error) {
+
+	store, err := s.resolve(kind)
+	if err != nil {
+		return nil, err
+	}
+	return store.List(kind)
+}
+
+func (s *SourceStore) Iterate(kind string, fn IterateFunc) error {
+
+	store, err := s.resolve(kind)
+	if err != nil {
+		return err
+	}
+	return store.Iterate(kind, fn)
+}
+
+// Close closes files opened by the source
+func (s *SourceStore) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res error
+	for path, store := range s.files {
+		if err := store.Close(); err != nil && res == nil {
+			res = err
+		}
+		delete(s.files, path)
+	}
+	return res
+}
+
+// resolve returns the store of the first kind file found in the folder
+func (s *SourceStore) resolve(kind string) (Store, error) {
+
+	// the log of the wrapped log store is read by the store itself
+	_, isLog := s.Store.(*LogStore)
+	for _, name := range getSourceNames(kind, !isLog) {
+		path := filepath.Join(s.dir, name)
+		if !isFile(path) {
+			continue
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if store, ok := s.files[path]; ok {
+			return store, nil
+		}
+		store, err := OpenFileReader(path)
+		if err != nil {
+			return nil, err
+		}
+		s.files[path] = store
+		return store, nil
+	}
+	return s.Store, nil
+}
+
+// getSourceNames returns possible file names of the kind in the order they are checked
+func getSourceNames(kind string, log bool) []string {
+
+	names := []string{}
+	for _, format := range []string{"json", "jsonl"} {
+		for _, compression := range append([]string{CompressNone}, Compressions...) {
+			ext, _ := GetCompressionExt(compression)
+			names = append(names, kind+"."+format+ext)
+		}
+	}
+	if log {
+		names = append(names, "itupod.log")
+	}
+	return names
+}
+
+func isFile(path string) bool {
+
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,49 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceStore(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	// plain and compressed files of different formats in the same folder
+	for _, opt := range [][2]string{{"json", CompressNone}, {"jsonl", CompressZstd}, {"json", CompressGzip}} {
+		store, err := OpenStore(&StoreOptions{Format: opt[0], Dir: dir, Compression: opt[1]})
+		assert.Nil(t, err)
+		kind := opt[0] + opt[1]
+		assert.Nil(t, store.Put(kind, 1, &testEntity{1, kind}))
+		assert.Nil(t, store.Close())
+	}
+
+	store, err := OpenStore(&StoreOptions{Format: "json", Dir: dir, Compression: CompressGzip})
+	assert.Nil(t, err)
+	src := OpenSource(dir, store)
+
+	assert.Equal(t, []*testEntity{{1, "json"}}, loadTestEntities(t, src, "json"))
+	assert.Equal(t, []*testEntity{{1, "jsonlzstd"}}, loadTestEntities(t, src, "jsonlzstd"))
+	assert.Equal(t, []*testEntity{{1, "jsongzip"}}, loadTestEntities(t, src, "jsongzip"))
+
+	en := &testEntity{}
+	assert.Nil(t, src.Get("jsonlzstd", 1, en))
+	assert.Equal(t, "jsonlzstd", en.Name)
+	ids, err := src.List("json")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, ids)
+
+	// kinds without files are read and written by the wrapped store
+	_, err = src.List("missing")
+	assert.NotNil(t, err)
+	assert.Nil(t, src.Put("written", 2, &testEntity{2, "written"}))
+	assert.Nil(t, store.Flush())
+	assert.FileExists(t, filepath.Join(dir, "written.json.gz"))
+
+	assert.Nil(t, src.Close())
+	assert.Nil(t, store.Close())
+}
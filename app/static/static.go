@@ -1,8 +1,11 @@
 package static
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 
 	"github.com/pkg/errors"
 )
@@ -17,7 +20,56 @@ func Save(path string, encoder DataEncoder) error {
 		return errors.Wrap(err, "Cannot save data")
 	}
 
-	return ioutil.WriteFile(path, data, 0644)
+	if data, err = compress(data, GetCompression(path)); err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// writeFile writes the data to the temporary file next to the path and renames it over the path,
+// so the interrupted write leaves either the previous or the new file, never the truncated one
+func writeFile(path string, data []byte) error {
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "Cannot create file")
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "Cannot write file")
+	}
+	return SyncDir(filepath.Dir(path))
+}
+
+// SyncDir flushes the folder entry, so the file renamed in the folder survives the crash
+func SyncDir(dir string) error {
+
+	if runtime.GOOS == "windows" {
+		// folders cannot be synced on windows, the rename is durable there
+		return nil
+	}
+
+	file, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "Cannot open folder")
+	}
+	defer file.Close()
+	return errors.Wrap(file.Sync(), "Cannot sync folder")
 }
 
 func Load(path string, decoder DataDecoder) error {
@@ -26,12 +78,60 @@ func Load(path string, decoder DataDecoder) error {
 		return errors.Wrap(err, "File is not exists")
 	}
 
-	file, err := ioutil.ReadFile(path)
+	reader, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	body, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return errors.Wrap(err, "Cannot read file")
 	}
 
-	return decoder(file)
+	return decoder(body)
+}
+
+// Open opens the file for streaming read, compressed files are decompressed transparently
+func Open(path string) (io.ReadCloser, error) {
+
+	if exists, err := isExists(path); err != nil || !exists {
+		return nil, errors.Wrap(err, "File is not exists")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open file")
+	}
+
+	reader, err := newDecompressReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &readCloser{reader, []io.Closer{reader, file}}, nil
+}
+
+// Create creates the file for streaming write, the compression is chosen by the file extension
+func Create(path string) (io.WriteCloser, error) {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot create file")
+	}
+
+	compression := GetCompression(path)
+	if compression == CompressNone {
+		return file, nil
+	}
+
+	writer, err := newCompressWriter(file, compression)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &writeCloser{writer, []io.Closer{writer, file}}, nil
 }
 
 func isExists(path string) (bool, error) {
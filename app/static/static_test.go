@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -31,6 +32,35 @@ func TestSave(t *testing.T) {
 	os.Remove(path)
 }
 
+func TestSaveAtomic(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "static.save.test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.json")
+
+	assert.Nil(t, Save(path, func() ([]byte, error) { return []byte("previous"), nil }))
+
+	// the failed encoding keeps the previous file
+	err := Save(path, func() ([]byte, error) { return nil, errors.New("Encoding error") })
+	assert.NotNil(t, err)
+	body, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "previous", string(body))
+
+	assert.Nil(t, Save(path, func() ([]byte, error) { return []byte("next"), nil }))
+	body, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "next", string(body))
+
+	stat, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0644), stat.Mode().Perm())
+
+	// temporary files are not left in the folder
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Len(t, entries, 1)
+
+	err = Save(filepath.Join(dir, "missing", "a.json"), func() ([]byte, error) { return []byte("a"), nil })
+	assert.NotNil(t, err)
+}
+
 func TestLoad(t *testing.T) {
 
 	path := "/tmp/static.load.test.txt"
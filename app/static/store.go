@@ -0,0 +1,163 @@
+package static
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when the entity is not present in the store
+var ErrNotFound = errors.New("Entity is not found")
+
+// Store persists entities grouped by kind (e.g. genres, shows.details) and identified by ID
+type Store interface {
+	Put(kind string, id int, entity interface{}) error
+	Get(kind string, id int, entity interface{}) error
+	List(kind string) ([]int, error)
+	Iterate(kind string, fn IterateFunc) error
+	Clear(kind string) error
+	Flush() error
+	Close() error
+}
+
+// IterateFunc is called for every entity in the store, decode fills the entity value
+type IterateFunc func(id int, decode EntityDecoder) error
+
+type EntityDecoder func(entity interface{}) error
+
+// Formats lists supported store formats
+var Formats = []string{"json", "jsonl", "log"}
+
+// StoreOptions describes the store to open
+type StoreOptions struct {
+	Format string
+	Dir    string
+	// Compression of json and jsonl files (see Compressions), empty compression means plain files
+	Compression string
+	// Generator and Country are written to the header of every new file
+	Generator string
+	Country   string
+}
+
+// OpenStore opens the store of the format in the folder
+func OpenStore(opt *StoreOptions) (Store, error) {
+
+	ext, err := GetCompressionExt(opt.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	header := newHeader(opt.Generator, opt.Country)
+	switch opt.Format {
+	case "json":
+		return newJSONStore(opt.Dir, ext, header), nil
+	case "jsonl":
+		return newJSONLStore(opt.Dir, ext, header), nil
+	case "log":
+		if opt.Compression != CompressNone {
+			return nil, errors.New("Compression is not supported by log store")
+		}
+		return newLogStore(filepath.Join(opt.Dir, "itupod.log"), header, true)
+	}
+	return nil, errors.Errorf("Unsupported store format: %s", opt.Format)
+}
+
+// FileName returns the name of the file which keeps the kind in the store folder
+func (opt *StoreOptions) FileName(kind string) (string, error) {
+
+	ext, err := GetCompressionExt(opt.Compression)
+	if err != nil {
+		return "", err
+	}
+
+	switch opt.Format {
+	case "json", "jsonl":
+		return kind + "." + opt.Format + ext, nil
+	case "log":
+		return "itupod.log", nil
+	}
+	return "", errors.Errorf("Unsupported store format: %s", opt.Format)
+}
+
+// OpenFile opens the store backed by the single file, all kinds are resolved to the file.
+// The format is chosen by the extension without the compression one, e.g. shows.jsonl.gz is opened as JSON Lines.
+func OpenFile(path string) (Store, error) {
+
+	return openFile(path, NewLogStore)
+}
+
+// OpenFileReader opens the file for reading only, the log is neither created nor repaired
+func OpenFileReader(path string) (Store, error) {
+
+	return openFile(path, OpenLogReader)
+}
+
+func openFile(path string, openLog func(path string) (*LogStore, error)) (Store, error) {
+
+	switch base := TrimCompressionExt(path); {
+	case strings.HasSuffix(base, ".jsonl"):
+		return newJSONLFileStore(path), nil
+	case strings.HasSuffix(base, ".log"):
+		return openLog(path)
+	}
+	return newJSONFileStore(path), nil
+}
+
+type collection struct {
+	order   []int
+	records map[int]json.RawMessage
+}
+
+func newCollection() *collection {
+
+	return &collection{[]int{}, map[int]json.RawMessage{}}
+}
+
+func (c *collection) put(id int, raw json.RawMessage) {
+
+	if _, ok := c.records[id]; !ok {
+		c.order = append(c.order, id)
+	}
+	c.records[id] = raw
+}
+
+func (c *collection) get(id int, entity interface{}) error {
+
+	raw, ok := c.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(raw, entity)
+}
+
+func (c *collection) list() []int {
+
+	return append([]int{}, c.order...)
+}
+
+func (c *collection) iterate(fn IterateFunc) error {
+
+	for _, id := range c.order {
+		raw := c.records[id]
+		err := fn(id, func(entity interface{}) error {
+			return json.Unmarshal(raw, entity)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getRecordID(raw json.RawMessage) (int, error) {
+
+	var rec struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return 0, errors.Wrap(err, "Cannot decode record")
+	}
+	return rec.ID, nil
+}
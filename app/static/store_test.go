@@ -0,0 +1,148 @@
+package static
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntity struct {
+	ID   int
+	Name string
+}
+
+func getTestDir(t *testing.T) string {
+
+	dir, err := ioutil.TempDir("", "static.store.test")
+	assert.Nil(t, err)
+	return dir
+}
+
+func mustMarshal(v interface{}) []byte {
+
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func loadTestEntities(t *testing.T, store Store, kind string) []*testEntity {
+
+	res := []*testEntity{}
+	err := store.Iterate(kind, func(id int, decode EntityDecoder) error {
+		en := &testEntity{}
+		if err := decode(en); err != nil {
+			return err
+		}
+		assert.Equal(t, id, en.ID)
+		res = append(res, en)
+		return nil
+	})
+	assert.Nil(t, err)
+	return res
+}
+
+func testStore(t *testing.T, open func() Store) {
+
+	store := open()
+	assert.Nil(t, store.Put("a", 1, &testEntity{1, "one"}))
+	assert.Nil(t, store.Put("a", 2, &testEntity{2, "two"}))
+	assert.Nil(t, store.Put("a", 1, &testEntity{1, "uno"}))
+	assert.Nil(t, store.Put("b", 3, &testEntity{3, "three"}))
+	assert.Nil(t, store.Close())
+
+	store = open()
+	ids, err := store.List("a")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []int{1, 2}, ids)
+
+	en := &testEntity{}
+	assert.Nil(t, store.Get("a", 1, en))
+	assert.Equal(t, &testEntity{1, "uno"}, en)
+	assert.Equal(t, ErrNotFound, store.Get("a", 3, en))
+
+	assert.ElementsMatch(t, []*testEntity{&testEntity{1, "uno"}, &testEntity{2, "two"}}, loadTestEntities(t, store, "a"))
+	assert.Equal(t, []*testEntity{&testEntity{3, "three"}}, loadTestEntities(t, store, "b"))
+
+	assert.Nil(t, store.Clear("a"))
+	assert.Nil(t, store.Put("a", 4, &testEntity{4, "four"}))
+	assert.Nil(t, store.Close())
+
+	store = open()
+	assert.Equal(t, []*testEntity{&testEntity{4, "four"}}, loadTestEntities(t, store, "a"))
+	assert.Nil(t, store.Close())
+}
+
+func TestOpenStore(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, format := range Formats {
+		store, err := OpenStore(&StoreOptions{Format: format, Dir: dir})
+		assert.Nil(t, err)
+		assert.NotNil(t, store)
+		store.Close()
+	}
+
+	for _, compression := range Compressions {
+		for _, format := range []string{"json", "jsonl"} {
+			testStore(t, func() Store {
+				store, err := OpenStore(&StoreOptions{Format: format, Dir: dir, Compression: compression})
+				assert.Nil(t, err)
+				return store
+			})
+		}
+	}
+	assert.FileExists(t, filepath.Join(dir, "a.json.gz"))
+	assert.FileExists(t, filepath.Join(dir, "a.jsonl.zst"))
+
+	_, err := OpenStore(&StoreOptions{Format: "xml", Dir: dir})
+	assert.Equal(t, "Unsupported store format: xml", err.Error())
+
+	_, err = OpenStore(&StoreOptions{Format: "json", Dir: dir, Compression: "lz4"})
+	assert.Equal(t, "Unsupported compression: lz4", err.Error())
+
+	_, err = OpenStore(&StoreOptions{Format: "log", Dir: dir, Compression: CompressGzip})
+	assert.NotNil(t, err)
+}
+
+func TestStoreOptionsFileName(t *testing.T) {
+
+	name, _ := (&StoreOptions{Format: "json"}).FileName("shows")
+	assert.Equal(t, "shows.json", name)
+
+	name, _ = (&StoreOptions{Format: "jsonl", Compression: CompressZstd}).FileName("shows.feed")
+	assert.Equal(t, "shows.feed.jsonl.zst", name)
+
+	name, _ = (&StoreOptions{Format: "log"}).FileName("genres")
+	assert.Equal(t, "itupod.log", name)
+
+	_, err := (&StoreOptions{Format: "xml"}).FileName("genres")
+	assert.NotNil(t, err)
+}
+
+func TestOpenFile(t *testing.T) {
+
+	dir := getTestDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "custom.json")
+	ioutil.WriteFile(path, []byte(`{"schema": 2, "records": [{"ID": 1, "Name": "one"}, {"id": 2, "name": "two"}]}`), 0644)
+
+	store, err := OpenFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []*testEntity{&testEntity{1, "one"}, &testEntity{2, "two"}}, loadTestEntities(t, store, "any"))
+
+	path = filepath.Join(dir, "custom.jsonl")
+	ioutil.WriteFile(path, []byte("{\"schema\": 2}\n{\"ID\": 1, \"Name\": \"one\"}\n\n{\"ID\": 1, \"Name\": \"uno\"}\n"), 0644)
+
+	store, err = OpenFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []*testEntity{&testEntity{1, "uno"}}, loadTestEntities(t, store, "any"))
+
+	store, _ = OpenFile(filepath.Join(dir, "missing.json"))
+	assert.NotNil(t, store.Iterate("any", func(id int, decode EntityDecoder) error { return nil }))
+}
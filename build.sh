@@ -4,8 +4,10 @@ cd app
 
 for GOOS in darwin linux windows; do
     for GOARCH in 386 amd64; do
+        # darwin/386 is not supported since Go 1.15
+        if [[ "${GOOS}" == "darwin" && "${GOARCH}" == "386" ]]; then continue; fi
         BIN_FILENAME="itupod-${GOOS}-${GOARCH}"
         if [[ "${GOOS}" == "windows" ]]; then BIN_FILENAME="${BIN_FILENAME}.exe"; fi
-        GOOS=${GOOS} GOARCH=${GOARCH} go build -v -o ../bin/$BIN_FILENAME
+        GOOS=${GOOS} GOARCH=${GOARCH} go build -v -ldflags "-X main.version=${VERSION:-dev}" -o ../bin/$BIN_FILENAME
     done
 done